@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+// how far back analytics go, hourly buckets only exist within the hourly
+// retention
+const analyticsMaxDays = 90
+
+// recordImpressions counts a view of every post by viewer, authors viewing
+// their own posts and anonymous views are not counted
+func (app *application) recordImpressions(viewer *store.User, posts ...*store.Post) {
+	if viewer == nil {
+		return
+	}
+
+	impressions := make([]store.Impression, 0, len(posts))
+	for _, p := range posts {
+		if p.UserID != viewer.ID {
+			impressions = append(impressions, store.NewImpression(p.ID, viewer.ID))
+		}
+	}
+
+	app.impressions.Add(impressions...)
+}
+
+// GetAnalytics godoc
+//
+//	@Summary		Fetches the analytics of the current user
+//	@Description	Views, reactions (reposts and quotes), comments and new followers over time. Views are counted once per viewer per hour.
+//	@Tags			users
+//	@Produce		json
+//	@Param			interval	query		string	false	"Bucket size, hour or day (default)"
+//	@Param			days		query		int		false	"Number of days to look back, 30 by default"
+//	@Success		200			{object}	store.Analytics
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/analytics [get]
+func (app *application) getAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	interval := qs.Get("interval")
+	if interval == "" {
+		interval = store.IntervalDay
+	}
+
+	maxDays := analyticsMaxDays
+	switch interval {
+	case store.IntervalDay:
+	case store.IntervalHour:
+		maxDays = int(app.config.analytics.hourlyRetention / (24 * time.Hour))
+	default:
+		app.badRequestResponse(w, r, fmt.Errorf("invalid interval %q, must be one of hour, day", interval))
+		return
+	}
+
+	days := min(30, maxDays)
+	if d := qs.Get("days"); d != "" {
+		var err error
+		days, err = strconv.Atoi(d)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	if days < 1 || days > maxDays {
+		app.badRequestResponse(w, r, fmt.Errorf("days must be between 1 and %d for the %s interval", maxDays, interval))
+		return
+	}
+
+	user := getUserFromCtx(r)
+	q := store.AnalyticsQuery{
+		Interval: interval,
+		Since:    time.Now().UTC().AddDate(0, 0, -days).Truncate(time.Hour),
+	}
+
+	analytics, err := app.store.Analytics.GetByUserID(r.Context(), user.ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, analytics); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
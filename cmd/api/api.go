@@ -18,9 +18,12 @@ import (
 	"github.com/yunsuk-jeung/social/docs"
 	"github.com/yunsuk-jeung/social/internal/auth"
 	"github.com/yunsuk-jeung/social/internal/mailer"
+	"github.com/yunsuk-jeung/social/internal/media"
 	"github.com/yunsuk-jeung/social/internal/ratelimiter"
 	"github.com/yunsuk-jeung/social/internal/store"
 	"github.com/yunsuk-jeung/social/internal/store/cache"
+	"github.com/yunsuk-jeung/social/internal/unfurl"
+	"github.com/yunsuk-jeung/social/internal/worker"
 	"go.uber.org/zap"
 )
 
@@ -32,18 +35,73 @@ type application struct {
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+	media         *media.Processor
+	mediaQueue    *worker.Queue[int64]
+	unfurler      *unfurl.Fetcher
+	linkQueue     *worker.Queue[string]
+	impressions   *worker.Batcher[store.Impression]
+	timelineQueue *worker.Queue[timelineJob]
 }
 
 type config struct {
-	addr        string
-	db          dbConfig
-	env         string
-	apiURL      string
-	frontendURL string
-	mail        mailConfig
-	auth        authConfig
-	redis       redisConfig
-	rateLimiter ratelimiter.Config
+	addr         string
+	db           dbConfig
+	env          string
+	apiURL       string
+	frontendURL  string
+	mail         mailConfig
+	auth         authConfig
+	redis        redisConfig
+	rateLimiter  ratelimiter.Config
+	media        mediaConfig
+	linkPreviews linkPreviewConfig
+	search       searchConfig
+	analytics    analyticsConfig
+	suggestions  suggestionConfig
+	timelines    timelineConfig
+	ranking      rankingConfig
+	usernames    store.UsernamePolicy
+	// posts a user can pin to their profile
+	pinnedPostsLimit int
+	// accounts a list can hold
+	listMembersLimit int
+}
+
+type analyticsConfig struct {
+	// impressions are buffered in memory and written in batches
+	flushInterval time.Duration
+	batchSize     int
+	// hourly view counts are kept this long before being rolled up by day
+	hourlyRetention time.Duration
+}
+
+type suggestionConfig struct {
+	// how often the follow suggestions of every user are recomputed
+	refreshInterval time.Duration
+	perUser         int
+}
+
+type searchConfig struct {
+	// text search configuration used when the request has no lang
+	language string
+}
+
+type linkPreviewConfig struct {
+	timeout  time.Duration
+	maxBytes int64
+	// how long a fetched preview is reused before the page is fetched again
+	ttl     time.Duration
+	workers int
+}
+
+type mediaConfig struct {
+	dir           string
+	baseURL       string
+	maxUploadSize int64
+	// images with more pixels are rejected before they are decoded
+	maxPixels int64
+	variants  []media.Variant
+	workers   int
 }
 
 type redisConfig struct {
@@ -92,9 +150,9 @@ func (app *application) mount() http.Handler {
 	r.Use(cors.Handler(cors.Options{
 		// AllowedOrigins:   []string{env.GetString("CORS_ALLOWED_ORIGIN", "http://localhost:5174")},
 		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
@@ -118,28 +176,118 @@ func (app *application) mount() http.Handler {
 		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
 		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
 
+		r.Get("/media/*", app.mediaFileHandler().ServeHTTP)
+
 		r.Route("/posts", func(r chi.Router) {
-			r.Use(app.AuthTokenMiddleware)
-			r.Post("/", app.createPostHandler)
+			r.With(app.AuthTokenMiddleware).Post("/", app.createPostHandler)
 
 			r.Route("/{postID}", func(r chi.Router) {
+				// public posts can be read anonymously
+				r.Use(app.OptionalAuthTokenMiddleware)
 				r.Use(app.postsContextMiddleware)
 
 				r.Get("/", app.getPostHandler)
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
-				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+				r.Get("/thread", app.getThreadHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.RequireUserMiddleware)
+
+					r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
+					r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+					r.Post("/comments", app.createCommentHandler)
+					r.Put("/repost", app.repostHandler)
+					r.Delete("/repost", app.unrepostHandler)
+					r.Put("/bookmark", app.bookmarkPostHandler)
+					r.Delete("/bookmark", app.unbookmarkPostHandler)
+					r.Post("/poll/votes", app.votePollHandler)
+					r.Put("/pin", app.pinPostHandler)
+					r.Delete("/pin", app.unpinPostHandler)
+
+					r.Route("/attachments", func(r chi.Router) {
+						r.Post("/", app.checkPostOwnership("admin", app.uploadAttachmentHandler))
+						r.Post("/{attachmentID}/variants", app.checkPostOwnership("admin", app.regenerateAttachmentVariantsHandler))
+					})
+				})
+			})
+		})
+
+		r.With(app.AuthTokenMiddleware).Get("/search", app.searchHandler)
+
+		r.Route("/tags", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+
+			r.Get("/trending", app.getTrendingTagsHandler)
+			r.Get("/{tag}/posts", app.getTagPostsHandler)
+		})
+
+		r.Route("/lists", func(r chi.Router) {
+			r.With(app.AuthTokenMiddleware).Post("/", app.createListHandler)
+
+			r.Route("/{listID}", func(r chi.Router) {
+				// public lists can be read anonymously
+				r.Use(app.OptionalAuthTokenMiddleware)
+				r.Use(app.listsContextMiddleware)
+
+				r.Get("/", app.getListHandler)
+				r.Get("/members", app.getListMembersHandler)
+				r.Get("/feed", app.getListFeedHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.RequireUserMiddleware)
+
+					r.Patch("/", app.checkListOwnership(app.updateListHandler))
+					r.Delete("/", app.checkListOwnership(app.deleteListHandler))
+					r.Put("/members/{userID}", app.checkListOwnership(app.addListMemberHandler))
+					r.Delete("/members/{userID}", app.checkListOwnership(app.removeListMemberHandler))
+				})
 			})
 		})
 
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.With(app.AuthTokenMiddleware).Get("/search", app.searchUsersHandler)
 
-			r.Route("/{userID}", func(r chi.Router) {
+			r.Route("/me", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 
+				r.Get("/notifications", app.getNotificationsHandler)
+				r.Put("/notifications/read", app.readNotificationsHandler)
+				r.Get("/bookmarks", app.getBookmarksHandler)
+				r.Get("/bookmarks/folders", app.getBookmarkFoldersHandler)
+				r.Get("/analytics", app.getAnalyticsHandler)
+				r.Patch("/", app.updateProfileHandler)
+				r.Put("/avatar", app.uploadAvatarHandler)
+				r.Put("/username", app.changeUsernameHandler)
+				r.Get("/blocks", app.getBlockedUsersHandler)
+				r.Get("/mutes", app.getMutedUsersHandler)
+				r.Get("/suggestions", app.getSuggestionsHandler)
+				r.Get("/follow-requests", app.getFollowRequestsHandler)
+				r.Post("/follow-requests/{userID}/approve", app.approveFollowRequestHandler)
+				r.Post("/follow-requests/{userID}/reject", app.rejectFollowRequestHandler)
+			})
+
+			r.With(app.OptionalAuthTokenMiddleware).Get("/by-username/{username}", app.getUserByUsernameHandler)
+
+			r.Route("/{userID}", func(r chi.Router) {
+				// public profiles and their posts can be read anonymously
+				r.Use(app.OptionalAuthTokenMiddleware)
+
 				r.Get("/", app.getUserHandler)
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Get("/posts", app.getUserPostsHandler)
+				r.Get("/lists", app.getUserListsHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.RequireUserMiddleware)
+
+					r.Get("/followers", app.getFollowersHandler)
+					r.Get("/following", app.getFollowingHandler)
+					r.Put("/follow", app.followUserHandler)
+					r.Put("/unfollow", app.unfollowUserHandler)
+					r.Put("/block", app.blockUserHandler)
+					r.Delete("/block", app.unblockUserHandler)
+					r.Put("/mute", app.muteUserHandler)
+					r.Delete("/mute", app.unmuteUserHandler)
+				})
 			})
 
 			r.Group(func(r chi.Router) {
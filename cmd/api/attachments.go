@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/yunsuk-jeung/social/internal/media"
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+// UploadAttachment godoc
+//
+//	@Summary		Uploads an image to a post
+//	@Description	Uploads an image to a post, variants are generated in the background
+//	@Tags			posts
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			file	formData	file	true	"Image file"
+//	@Success		201		{object}	store.Attachment
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		413		{object}	error
+//	@Failure		415		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/attachments [post]
+func (app *application) uploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+
+	upload, err := app.readImageUpload(w, r)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrUnsupportedType):
+			app.unsupportedMediaTypeResponse(w, r, err)
+		case errors.Is(err, media.ErrTooLarge):
+			app.payloadTooLargeResponse(w, r, err)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	defer upload.Close()
+
+	attachment := &store.Attachment{
+		PostID:      post.ID,
+		UserID:      user.ID,
+		Key:         path.Join("attachments", strconv.FormatInt(post.ID, 10), uuid.New().String(), "original"+upload.ext),
+		ContentType: upload.contentType,
+		Size:        upload.size,
+	}
+
+	if err := app.media.Save(attachment.Key, upload); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Attachments.Create(r.Context(), attachment); err != nil {
+		app.deleteUpload(attachment.Key)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.mediaQueue.Enqueue(attachment.ID)
+	app.setAttachmentURLs(attachment)
+
+	if err := app.jsonResponse(w, http.StatusCreated, attachment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// imageUpload is the "file" part of a multipart upload, its content type is
+// sniffed instead of trusting the client supplied header
+type imageUpload struct {
+	io.Reader
+	file        multipart.File
+	contentType string
+	ext         string
+	size        int64
+}
+
+func (u *imageUpload) Close() error {
+	return u.file.Close()
+}
+
+// readImageUpload fails with media.ErrUnsupportedType when the file is not
+// an image that can be processed and media.ErrTooLarge when it is over the
+// pixel limit, before anything is stored
+func (app *application) readImageUpload(w http.ResponseWriter, r *http.Request) (*imageUpload, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.media.maxUploadSize)
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		file.Close()
+		return nil, err
+	}
+
+	contentType := http.DetectContentType(sniff[:n])
+	ext, err := media.ExtensionFor(contentType)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// the header read to check the dimensions is replayed with the rest of
+	// the file
+	var head bytes.Buffer
+	if err := app.media.CheckDimensions(io.TeeReader(io.MultiReader(bytes.NewReader(sniff[:n]), file), &head)); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &imageUpload{
+		Reader:      io.MultiReader(&head, file),
+		file:        file,
+		contentType: contentType,
+		ext:         ext,
+		size:        header.Size,
+	}, nil
+}
+
+// RegenerateAttachmentVariants godoc
+//
+//	@Summary		Regenerates missing image variants
+//	@Description	Queues the generation of variants that are missing for an attachment
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID			path	int	true	"Post ID"
+//	@Param			attachmentID	path	int	true	"Attachment ID"
+//	@Success		202				{object}	store.Attachment
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/attachments/{attachmentID}/variants [post]
+func (app *application) regenerateAttachmentVariantsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "attachmentID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	attachment, err := app.store.Attachments.GetByID(r.Context(), attachmentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if attachment.PostID != post.ID {
+		app.notFoundResponse(w, r, fmt.Errorf("attachment %d does not belong to post %d", attachment.ID, post.ID))
+		return
+	}
+
+	status := http.StatusOK
+	if len(app.media.Missing(variantImages(attachment))) > 0 {
+		app.mediaQueue.Enqueue(attachment.ID)
+		status = http.StatusAccepted
+	}
+
+	app.setAttachmentURLs(attachment)
+
+	if err := app.jsonResponse(w, status, attachment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// processAttachment is run by the media workers, it fills in the dimensions
+// and blurhash of an attachment and writes its missing variants. Attachments
+// whose image cannot be processed are deleted along with their files.
+func (app *application) processAttachment(ctx context.Context, attachmentID int64) error {
+	attachment, err := app.store.Attachments.GetByID(ctx, attachmentID)
+	if err != nil {
+		return err
+	}
+
+	img, err := app.media.Generate(attachment.Key, variantImages(attachment))
+	if err != nil {
+		// retrying will not help an image that cannot be processed
+		if errors.Is(err, media.ErrTooLarge) || errors.Is(err, media.ErrInvalidImage) {
+			if err := app.store.Attachments.Delete(ctx, attachment.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return fmt.Errorf("attachment %d: %w", attachment.ID, err)
+			}
+			app.deleteUpload(attachment.Key)
+		}
+		return fmt.Errorf("attachment %d: %w", attachment.ID, err)
+	}
+
+	attachment.Width = img.Width
+	attachment.Height = img.Height
+	attachment.BlurHash = img.BlurHash
+	attachment.Variants = make(map[string]store.AttachmentVariant, len(img.Variants))
+	for name, v := range img.Variants {
+		attachment.Variants[name] = store.AttachmentVariant{Width: v.Width, Height: v.Height}
+	}
+
+	return app.store.Attachments.UpdateImage(ctx, attachment)
+}
+
+// withAttachments loads the attachments of every post in one query
+func (app *application) withAttachments(ctx context.Context, posts ...*store.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+
+	attachments, err := app.store.Attachments.GetByPostIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		p.Attachments = attachments[p.ID]
+		for i := range p.Attachments {
+			app.setAttachmentURLs(&p.Attachments[i])
+		}
+	}
+
+	return nil
+}
+
+func (app *application) setAttachmentURLs(a *store.Attachment) {
+	a.URL = app.mediaURL(a.Key)
+	for name, v := range a.Variants {
+		v.URL = app.mediaURL(a.VariantKey(name))
+		a.Variants[name] = v
+	}
+}
+
+// deleteUpload removes an upload that is no longer referenced, a failure only
+// leaves a file behind
+func (app *application) deleteUpload(key string) {
+	if err := app.media.Delete(key); err != nil {
+		app.logger.Errorw("error deleting upload", "key", key, "error", err)
+	}
+}
+
+func (app *application) mediaURL(key string) string {
+	return strings.TrimSuffix(app.config.media.baseURL, "/") + "/" + key
+}
+
+func (app *application) mediaFileHandler() http.Handler {
+	fs := http.StripPrefix("/v1/media", http.FileServer(http.Dir(app.config.media.dir)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// no directory listings
+		if strings.HasSuffix(r.URL.Path, "/") {
+			app.notFoundResponse(w, r, fmt.Errorf("directory listing is not allowed"))
+			return
+		}
+		fs.ServeHTTP(w, r)
+	})
+}
+
+func variantImages(a *store.Attachment) map[string]media.VariantImage {
+	variants := make(map[string]media.VariantImage, len(a.Variants))
+	for name, v := range a.Variants {
+		variants[name] = media.VariantImage{
+			Key:    a.VariantKey(name),
+			Width:  v.Width,
+			Height: v.Height,
+		}
+	}
+	return variants
+}
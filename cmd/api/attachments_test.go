@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/yunsuk-jeung/social/internal/media"
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+func TestUploadAttachmentTooLarge(t *testing.T) {
+	cfg := config{
+		media: mediaConfig{
+			dir:           t.TempDir(),
+			maxUploadSize: 1 << 20,
+			maxPixels:     100*100 - 1,
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	app.media = media.NewProcessor(cfg.media.dir, nil, cfg.media.maxPixels)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "large.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(part, image.NewGray(image.Rect(0, 0, 100, 100))); err != nil {
+		t.Fatal(err)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/attachments", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	ctx := context.WithValue(req.Context(), postCtx, &store.Post{ID: 1})
+	ctx = context.WithValue(ctx, userCtx, &store.User{ID: 1})
+
+	rr := exceteRequest(req.WithContext(ctx), http.HandlerFunc(app.uploadAttachmentHandler))
+
+	checkResponseCode(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	// nothing is stored before the image is rejected
+	entries, err := os.ReadDir(cfg.media.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected an empty media dir, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+// BookmarkPayload moves the bookmark to Folder when it is given, an empty one
+// takes it out of its folder
+type BookmarkPayload struct {
+	Folder *string `json:"folder" validate:"omitempty,max=100"`
+}
+
+// BookmarkPost godoc
+//
+//	@Summary		Bookmarks a post
+//	@Description	Saves a post for later, optionally in a named folder. Bookmarking again moves the post to the given folder, an empty folder takes it out of its folder and no folder leaves it where it is.
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		BookmarkPayload	false	"Bookmark folder"
+//	@Success		204		{string}	string			"Post bookmarked"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [put]
+func (app *application) bookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	// the payload is optional
+	var payload BookmarkPayload
+	if err := readJSON(w, r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Folder != nil {
+		folder := strings.TrimSpace(*payload.Folder)
+		payload.Folder = &folder
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Bookmarks.Save(r.Context(), user.ID, post.ID, payload.Folder); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UnbookmarkPost godoc
+//
+//	@Summary		Removes a bookmark
+//	@Description	Removes a post from the bookmarks of the current user
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Bookmark removed"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [delete]
+func (app *application) unbookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	if err := app.store.Bookmarks.Delete(r.Context(), user.ID, post.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetBookmarks godoc
+//
+//	@Summary		Fetches the bookmarks of the current user
+//	@Description	Fetches the bookmarked posts, most recently saved first
+//	@Tags			users
+//	@Produce		json
+//	@Param			folder	query		string	false	"Folder name"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			format	query		string	false	"Content format, raw (default) or html"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks [get]
+func (app *application) getBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	pq := store.PaginatedQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	pq, err := pq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	format, err := parseContentFormat(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	folder := strings.TrimSpace(r.URL.Query().Get("folder"))
+
+	feed, err := app.store.Bookmarks.GetByUserID(ctx, user.ID, folder, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.preparePosts(ctx, user, format, feedPosts(feed)...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetBookmarkFolders godoc
+//
+//	@Summary		Fetches the bookmark folders of the current user
+//	@Description	Fetches the bookmark folders with the number of posts in each
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.BookmarkFolder
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks/folders [get]
+func (app *application) getBookmarkFoldersHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	folders, err := app.store.Bookmarks.GetFolders(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, folders); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// withBookmarked flags the posts the viewer has bookmarked, the flag is left
+// out for anonymous viewers
+func (app *application) withBookmarked(ctx context.Context, viewer *store.User, posts ...*store.Post) error {
+	if viewer == nil || len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+
+	bookmarked, err := app.store.Bookmarks.GetBookmarked(ctx, viewer.ID, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		b := bookmarked[p.ID]
+		p.Bookmarked = &b
+	}
+
+	return nil
+}
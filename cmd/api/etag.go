@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// postETag is the entity tag of a post, its version. The version is bumped
+// on every edit so it changes whenever the title, content or tags do.
+func postETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// postBodyETag is the entity tag of a post as it is read: its version, then
+// a hash of the response body, which changes with the comments, counts,
+// votes, bookmark and content format it carries
+func postBodyETag(version int, body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + strconv.Itoa(version) + "." + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// ifMatchVersion reports whether an If-Match header value lists a tag of the
+// post at version, from postETag or postBodyETag. It uses the strong
+// comparison, a weak tag never satisfies the precondition.
+func ifMatchVersion(header string, version int) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+
+		if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+			continue
+		}
+
+		v, _, _ := strings.Cut(tag[1:len(tag)-1], ".")
+		if v == strconv.Itoa(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// ifNoneMatch reports whether an If-None-Match header value lists etag. It
+// uses the weak comparison, weak tags are compared by their opaque value.
+func ifNoneMatch(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestETagMatches(t *testing.T) {
+	etag := postETag(3)
+	body := postBodyETag(3, []byte(`{"data":{}}`))
+
+	tests := []struct {
+		header    string
+		match     bool
+		noneMatch bool
+	}{
+		{`"3"`, true, true},
+		{`W/"3"`, false, true},
+		{`"1", "3"`, true, true},
+		{`"1", W/"3"`, false, true},
+		{`*`, true, true},
+		{`"2"`, false, false},
+		{`W/"2"`, false, false},
+		{`3`, false, false},
+		{``, false, false},
+		{body, true, false},
+		{"W/" + body, false, false},
+		{`"2.0123456789abcdef"`, false, false},
+		{`"3.`, false, false},
+	}
+
+	for _, tt := range tests {
+		if got := ifMatchVersion(tt.header, 3); got != tt.match {
+			t.Errorf("ifMatchVersion(%q, 3) = %v, want %v", tt.header, got, tt.match)
+		}
+		if got := ifNoneMatch(tt.header, etag); got != tt.noneMatch {
+			t.Errorf("ifNoneMatch(%q, %q) = %v, want %v", tt.header, etag, got, tt.noneMatch)
+		}
+	}
+
+	if !ifNoneMatch("W/"+body, body) {
+		t.Errorf("ifNoneMatch(%q, %q) = false, want true", "W/"+body, body)
+	}
+
+	if other := postBodyETag(3, []byte(`{"data":{"comments":[]}}`)); other == body {
+		t.Errorf("postBodyETag() = %q for different bodies", other)
+	}
+}
@@ -1,14 +1,36 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/yunsuk-jeung/social/internal/store"
 )
 
+// GetUserFeed godoc
+//
+//	@Summary		Fetches the user feed
+//	@Description	Fetches the posts and reposts of the current user and the users they follow. Pages are chained with the next_cursor of the previous page, also sent as a Link header. Offset is deprecated and ignored with a cursor. The ranked mode orders recent posts by a score of recency, engagement, affinity with the author and interest in their tags, the score of each post is explained with debug=true when ranking debug is on or for admins.
+//	@Tags			feed
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"next_cursor of the previous page"
+//	@Param			offset	query		int		false	"Offset, deprecated"
+//	@Param			sort	query		string	false	"asc or desc (default)"
+//	@Param			tags	query		string	false	"Comma separated tags"
+//	@Param			search	query		string	false	"Search in title and content"
+//	@Param			format	query		string	false	"Content format, raw (default) or html"
+//	@Param			mode	query		string	false	"chronological (default) or ranked"
+//	@Param			debug	query		bool	false	"Explain the scores of a ranked feed"
+//	@Success		200		{object}	feedPage
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/feed [get]
 func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
 	// pagination, filters
-	// ex) feed?limit=20&offset=0
+	// ex) feed?limit=20&cursor=...
 	fq := store.PaginatedFeedQuery{
 		Limit:  20,
 		Offset: 0,
@@ -21,22 +43,94 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	format, err := parseContentFormat(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	mode, err := parseFeedMode(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	if err = Validate.Struct(fq); err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
+	// cursors of one mode do not page the other
+	if fq.Cursor != nil && (fq.Cursor.Score != nil) != (mode == feedModeRanked) {
+		app.badRequestResponse(w, r, store.ErrInvalidCursor)
+		return
+	}
+
 	ctx := r.Context()
-	//TODO: to auth
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(1), fq)
+	user := getUserFromCtx(r)
+
+	var (
+		feed []store.PostWithMetadata
+		next *store.FeedCursor
+	)
+
+	switch mode {
+	case feedModeRanked:
+		if fq.Sort != "desc" || fq.Search != "" || len(fq.Tags) > 0 {
+			app.badRequestResponse(w, r, errRankedQuery)
+			return
+		}
+
+		explain, explainErr := app.explainRanking(r, user)
+		if explainErr != nil {
+			app.internalServerError(w, r, explainErr)
+			return
+		}
+
+		feed, next, err = app.rankedFeed(ctx, user, fq, explain)
+	default:
+		feed, next, err = app.homeFeed(ctx, user, fq)
+	}
 
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+	if err := app.preparePosts(ctx, user, format, feedPosts(feed)...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.recordImpressions(user, feedPosts(feed)...)
+
+	if err := app.feedResponse(w, r, feed, next); err != nil {
 		app.internalServerError(w, r, err)
 	}
 
 }
+
+// feedPage is a page of a feed, next_cursor is null on the last page
+type feedPage struct {
+	Data       []store.PostWithMetadata `json:"data"`
+	NextCursor *string                  `json:"next_cursor"`
+}
+
+// feedResponse writes a page of a feed with the cursor of the next one, in
+// the body and as a Link header to the same request from that cursor
+func (app *application) feedResponse(w http.ResponseWriter, r *http.Request, feed []store.PostWithMetadata, next *store.FeedCursor) error {
+	page := feedPage{Data: feed}
+
+	if next != nil {
+		cursor := next.String()
+		page.NextCursor = &cursor
+
+		qs := r.URL.Query()
+		qs.Set("cursor", cursor)
+		qs.Del("offset")
+
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, qs.Encode()))
+	}
+
+	return writeJSON(w, http.StatusOK, page)
+}
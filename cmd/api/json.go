@@ -5,12 +5,22 @@ import (
 	"net/http"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/yunsuk-jeung/social/internal/extract"
+	"github.com/yunsuk-jeung/social/internal/search"
 )
 
 var Validate *validator.Validate
 
 func init() {
 	Validate = validator.New(validator.WithRequiredStructEnabled())
+
+	_ = Validate.RegisterValidation("search_language", func(fl validator.FieldLevel) bool {
+		return search.IsLanguage(fl.Field().String())
+	})
+
+	_ = Validate.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return extract.IsUsername(fl.Field().String())
+	})
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) error {
@@ -36,6 +46,11 @@ func writeJSONError(w http.ResponseWriter, status int, message string) error {
 	return writeJSON(w, status, &envelope{Error: message})
 }
 
+// envelope wraps the data of a successful response
+type envelope struct {
+	Data any `json:"data"`
+}
+
 func (app *application) jsonResponse(w http.ResponseWriter, status int, data any) error {
 
 	if status == http.StatusNoContent || data == nil {
@@ -43,9 +58,5 @@ func (app *application) jsonResponse(w http.ResponseWriter, status int, data any
 		return nil
 	}
 
-	type envelope struct {
-		Data any `json:"data"`
-	}
-
 	return writeJSON(w, status, &envelope{Data: data})
 }
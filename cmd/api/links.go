@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yunsuk-jeung/social/internal/extract"
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+// only the first links of a post get a preview
+const maxLinksPerPost = 4
+
+// setPostLinks stores the links found in the post content and queues the
+// ones without a fresh preview to be unfurled in the background
+func (app *application) setPostLinks(ctx context.Context, post *store.Post) error {
+	urls := extract.URLs(post.Content)
+	if len(urls) > maxLinksPerPost {
+		urls = urls[:maxLinksPerPost]
+	}
+
+	if err := app.store.Links.SetForPost(ctx, post.ID, urls); err != nil {
+		return err
+	}
+
+	for _, url := range urls {
+		app.linkQueue.Enqueue(url)
+	}
+
+	return nil
+}
+
+// unfurlLink is run by the link preview workers. Failures are cached like
+// successful fetches so a dead link is not retried before the TTL expires.
+func (app *application) unfurlLink(ctx context.Context, url string) error {
+	_, err := app.store.Links.GetPreview(ctx, url, app.config.linkPreviews.ttl)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	preview := &store.LinkPreview{URL: url}
+
+	p, fetchErr := app.unfurler.Fetch(ctx, url)
+	if fetchErr != nil {
+		preview.Failed = true
+	} else {
+		preview.Title = p.Title
+		preview.Description = p.Description
+		preview.ImageURL = p.ImageURL
+		preview.SiteName = p.SiteName
+	}
+
+	if err := app.store.Links.SavePreview(ctx, preview); err != nil {
+		return err
+	}
+
+	if fetchErr != nil {
+		app.logger.Infow("link preview failed", "url", url, "error", fetchErr.Error())
+	}
+
+	return nil
+}
+
+// withLinkPreviews loads the previews of the links of every post in one query
+func (app *application) withLinkPreviews(ctx context.Context, posts ...*store.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+
+	previews, err := app.store.Links.GetPreviewsByPostIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		p.LinkPreviews = previews[p.ID]
+	}
+
+	return nil
+}
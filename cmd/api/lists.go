@@ -0,0 +1,532 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+type listKey string
+
+const listCtx listKey = "list"
+
+type CreateListPayload struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"max=500"`
+	IsPrivate   bool   `json:"is_private"`
+}
+
+type UpdateListPayload struct {
+	Name        *string `json:"name" validate:"omitempty,min=1,max=100"`
+	Description *string `json:"description" validate:"omitempty,max=500"`
+	IsPrivate   *bool   `json:"is_private"`
+}
+
+// CreateList godoc
+//
+//	@Summary		Creates a list
+//	@Description	Creates a list of accounts owned by the current user, a private list is only seen by its owner
+//	@Tags			lists
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateListPayload	true	"List payload"
+//	@Success		201		{object}	store.List
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/lists [post]
+func (app *application) createListHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateListPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	list := &store.List{
+		UserID:      getUserFromCtx(r).ID,
+		Name:        payload.Name,
+		Description: payload.Description,
+		IsPrivate:   payload.IsPrivate,
+	}
+
+	if err := app.store.Lists.Create(r.Context(), list); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, list); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetList godoc
+//
+//	@Summary		Fetches a list
+//	@Tags			lists
+//	@Produce		json
+//	@Param			listID	path		int	true	"List ID"
+//	@Success		200		{object}	store.List
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/lists/{listID} [get]
+func (app *application) getListHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getListFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateList godoc
+//
+//	@Summary		Updates a list
+//	@Description	Updates the name, description or privacy of a list of the current user
+//	@Tags			lists
+//	@Accept			json
+//	@Produce		json
+//	@Param			listID	path		int					true	"List ID"
+//	@Param			payload	body		UpdateListPayload	true	"List payload"
+//	@Success		200		{object}	store.List
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/lists/{listID} [patch]
+func (app *application) updateListHandler(w http.ResponseWriter, r *http.Request) {
+	list := getListFromCtx(r)
+
+	var payload UpdateListPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Name != nil {
+		list.Name = *payload.Name
+	}
+
+	if payload.Description != nil {
+		list.Description = *payload.Description
+	}
+
+	if payload.IsPrivate != nil {
+		list.IsPrivate = *payload.IsPrivate
+	}
+
+	if err := app.store.Lists.Update(r.Context(), list); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, list); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteList godoc
+//
+//	@Summary		Deletes a list
+//	@Tags			lists
+//	@Produce		json
+//	@Param			listID	path		int		true	"List ID"
+//	@Success		204		{string}	string	"List deleted"
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/lists/{listID} [delete]
+func (app *application) deleteListHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.store.Lists.Delete(r.Context(), getListFromCtx(r).ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetListMembers godoc
+//
+//	@Summary		Fetches the members of a list
+//	@Description	Fetches the members of a list, most recently added first
+//	@Tags			lists
+//	@Produce		json
+//	@Param			listID	path		int	true	"List ID"
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/lists/{listID}/members [get]
+func (app *application) getListMembersHandler(w http.ResponseWriter, r *http.Request) {
+	pq := store.PaginatedQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	pq, err := pq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var viewerID int64
+	if viewer := getUserFromCtx(r); viewer != nil {
+		viewerID = viewer.ID
+	}
+
+	members, err := app.store.Lists.GetMembers(r.Context(), getListFromCtx(r).ID, viewerID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range members {
+		app.setAvatarURLs(&members[i])
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, members); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// AddListMember godoc
+//
+//	@Summary		Adds a user to a list
+//	@Tags			lists
+//	@Produce		json
+//	@Param			listID	path		int		true	"List ID"
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"Member added"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Already a member or members limit reached"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/lists/{listID}/members/{userID} [put]
+func (app *application) addListMemberHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	list := getListFromCtx(r)
+
+	if _, err := app.getUser(ctx, userID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.checkNotBlocked(ctx, getUserFromCtx(r), userID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.store.Lists.AddMember(ctx, list.ID, userID, app.config.listMembersLimit)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, err)
+		case errors.Is(err, store.ErrListFull):
+			app.conflictResponse(w, r, fmt.Errorf("a list holds at most %d accounts", app.config.listMembersLimit))
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RemoveListMember godoc
+//
+//	@Summary		Removes a user from a list
+//	@Tags			lists
+//	@Produce		json
+//	@Param			listID	path		int		true	"List ID"
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"Member removed"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/lists/{listID}/members/{userID} [delete]
+func (app *application) removeListMemberHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Lists.RemoveMember(r.Context(), getListFromCtx(r).ID, userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetListFeed godoc
+//
+//	@Summary		Fetches the timeline of a list
+//	@Description	Fetches the posts and reposts of the members of a list, with the same filters as the user feed
+//	@Tags			lists
+//	@Produce		json
+//	@Param			listID	path		int		true	"List ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"next_cursor of the previous page"
+//	@Param			offset	query		int		false	"Offset, deprecated"
+//	@Param			sort	query		string	false	"asc or desc (default)"
+//	@Param			tags	query		string	false	"Comma separated tags"
+//	@Param			search	query		string	false	"Search in title and content"
+//	@Param			format	query		string	false	"Content format, raw (default) or html"
+//	@Success		200		{object}	feedPage
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/lists/{listID}/feed [get]
+func (app *application) getListFeedHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	format, err := parseContentFormat(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err = Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	viewer := getUserFromCtx(r)
+
+	var viewerID int64
+	if viewer != nil {
+		viewerID = viewer.ID
+	}
+
+	feed, next, err := app.store.Posts.GetListFeed(ctx, getListFromCtx(r).ID, viewerID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.preparePosts(ctx, viewer, format, feedPosts(feed)...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.recordImpressions(viewer, feedPosts(feed)...)
+
+	if err := app.feedResponse(w, r, feed, next); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetUserLists godoc
+//
+//	@Summary		Fetches the lists of a user
+//	@Description	Fetches the lists a user owns, most recent first, private lists only to their owner
+//	@Tags			lists
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.List
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/lists [get]
+func (app *application) getUserListsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	pq := store.PaginatedQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	pq, err = pq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	viewer := getUserFromCtx(r)
+
+	if err := app.checkNotBlocked(ctx, viewer, userID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	owner := viewer != nil && viewer.ID == userID
+
+	lists, err := app.store.Lists.GetByUserID(ctx, userID, owner, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, lists); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// listsContextMiddleware loads the list of the route, lists the viewer may
+// not see, private ones of other users or of users they are blocked with,
+// are not found
+func (app *application) listsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "listID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		list, err := app.store.Lists.GetByID(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		viewer := getUserFromCtx(r)
+		owner := viewer != nil && viewer.ID == list.UserID
+
+		if list.IsPrivate && !owner {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
+		if err := app.checkNotBlocked(ctx, viewer, list.UserID); err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, listCtx, list)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// checkListOwnership lets only the owner of the list through
+func (app *application) checkListOwnership(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if getListFromCtx(r).UserID != getUserFromCtx(r).ID {
+			app.forbiddenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func getListFromCtx(r *http.Request) *store.List {
+	list, _ := r.Context().Value(listCtx).(*store.List)
+	return list
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"expvar"
 	"runtime"
 	"time"
@@ -10,9 +11,13 @@ import (
 	"github.com/yunsuk-jeung/social/internal/db"
 	"github.com/yunsuk-jeung/social/internal/env"
 	"github.com/yunsuk-jeung/social/internal/mailer"
+	"github.com/yunsuk-jeung/social/internal/media"
 	"github.com/yunsuk-jeung/social/internal/ratelimiter"
+	"github.com/yunsuk-jeung/social/internal/search"
 	"github.com/yunsuk-jeung/social/internal/store"
 	"github.com/yunsuk-jeung/social/internal/store/cache"
+	"github.com/yunsuk-jeung/social/internal/unfurl"
+	"github.com/yunsuk-jeung/social/internal/worker"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -77,6 +82,55 @@ func main() {
 			TimeFrame:            time.Second * 5,
 			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
 		},
+		media: mediaConfig{
+			dir:           env.GetString("MEDIA_DIR", "./uploads"),
+			baseURL:       env.GetString("MEDIA_URL", "http://localhost:3000/v1/media"),
+			maxUploadSize: int64(env.GetInt("MEDIA_MAX_UPLOAD_SIZE", 10<<20)), // 10MB
+			maxPixels:     int64(env.GetInt("MEDIA_MAX_PIXELS", 40_000_000)),
+			workers:       env.GetInt("MEDIA_WORKERS", 2),
+		},
+		linkPreviews: linkPreviewConfig{
+			timeout:  time.Second * 5,
+			maxBytes: int64(env.GetInt("LINK_PREVIEW_MAX_BYTES", 512<<10)), // 512KB
+			ttl:      time.Hour * 24,
+			workers:  env.GetInt("LINK_PREVIEW_WORKERS", 2),
+		},
+		search: searchConfig{
+			language: env.GetString("SEARCH_LANGUAGE", "english"),
+		},
+		analytics: analyticsConfig{
+			flushInterval:   time.Second * 10,
+			batchSize:       env.GetInt("ANALYTICS_BATCH_SIZE", 1000),
+			hourlyRetention: time.Hour * 24 * 7,
+		},
+		suggestions: suggestionConfig{
+			refreshInterval: time.Hour,
+			perUser:         env.GetInt("SUGGESTIONS_PER_USER", 20),
+		},
+		timelines: timelineConfig{
+			length:             env.GetInt("TIMELINE_LENGTH", 800),
+			ttl:                time.Hour * 24,
+			celebrityFollowers: env.GetInt("TIMELINE_CELEBRITY_FOLLOWERS", 10000),
+			workers:            env.GetInt("TIMELINE_WORKERS", 2),
+		},
+		ranking: rankingConfig{
+			recencyWeight:     env.GetFloat("FEED_RANKING_RECENCY_WEIGHT", 1),
+			engagementWeight:  env.GetFloat("FEED_RANKING_ENGAGEMENT_WEIGHT", 0.5),
+			affinityWeight:    env.GetFloat("FEED_RANKING_AFFINITY_WEIGHT", 0.8),
+			tagInterestWeight: env.GetFloat("FEED_RANKING_TAG_INTEREST_WEIGHT", 0.6),
+			halfLife:          time.Hour * time.Duration(env.GetInt("FEED_RANKING_HALF_LIFE_HOURS", 6)),
+			window:            time.Hour * time.Duration(env.GetInt("FEED_RANKING_WINDOW_HOURS", 72)),
+			interestWindow:    time.Hour * 24 * 30,
+			candidates:        env.GetInt("FEED_RANKING_CANDIDATES", 500),
+			debug:             env.GetBool("FEED_RANKING_DEBUG", false),
+		},
+		usernames: store.UsernamePolicy{
+			Cooldown:    time.Hour * 24 * time.Duration(env.GetInt("USERNAME_COOLDOWN_DAYS", 30)),
+			Redirect:    time.Hour * 24 * 30,
+			Reservation: time.Hour * 24 * time.Duration(env.GetInt("USERNAME_RESERVATION_DAYS", 90)),
+		},
+		pinnedPostsLimit: env.GetInt("PINNED_POSTS_LIMIT", 3),
+		listMembersLimit: env.GetInt("LIST_MEMBERS_LIMIT", 500),
 	}
 
 	// Logger
@@ -95,6 +149,16 @@ func main() {
 	// logger := zap.Must(zap.NewProduction()).Sugar()
 	defer logger.Sync()
 
+	variants, err := media.ParseVariants(env.GetString("MEDIA_VARIANTS", "thumbnail:160,medium:640"))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	cfg.media.variants = variants
+
+	if !search.IsLanguage(cfg.search.language) {
+		logger.Fatalf("unsupported SEARCH_LANGUAGE %q", cfg.search.language)
+	}
+
 	// Database
 	db, err := db.New(
 		cfg.db.addr,
@@ -138,8 +202,38 @@ func main() {
 		mailer:        mailer,
 		authenticator: jwtAuthenticator,
 		rateLimiter:   rateLimiter,
+		media:         media.NewProcessor(cfg.media.dir, cfg.media.variants, cfg.media.maxPixels),
+		unfurler:      unfurl.NewFetcher(cfg.linkPreviews.timeout, cfg.linkPreviews.maxBytes),
 	}
 
+	// Background workers
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app.mediaQueue = worker.NewQueue("media", 100, logger, app.processAttachment)
+	app.mediaQueue.Start(ctx, cfg.media.workers)
+
+	app.linkQueue = worker.NewQueue("link previews", 100, logger, app.unfurlLink)
+	app.linkQueue.Start(ctx, cfg.linkPreviews.workers)
+
+	worker.Every(ctx, "prune tag usages", time.Hour, logger, func(ctx context.Context) error {
+		return app.store.Tags.Prune(ctx, tagUsageRetention)
+	})
+
+	app.timelineQueue = worker.NewQueue("timelines", 1000, logger, app.fanOut)
+	app.timelineQueue.Start(ctx, cfg.timelines.workers)
+
+	app.impressions = worker.NewBatcher("impressions", cfg.analytics.batchSize, cfg.analytics.flushInterval, logger, app.store.Analytics.RecordImpressions)
+	app.impressions.Start(ctx)
+
+	worker.Every(ctx, "roll up post views", time.Hour, logger, func(ctx context.Context) error {
+		return app.store.Analytics.Rollup(ctx, cfg.analytics.hourlyRetention)
+	})
+
+	worker.Every(ctx, "refresh follow suggestions", cfg.suggestions.refreshInterval, logger, func(ctx context.Context) error {
+		return app.store.Suggestions.Refresh(ctx, cfg.suggestions.perUser)
+	})
+
 	// Metrics collected
 	expvar.NewString("version").Set(version)
 	expvar.Publish("database", expvar.Func(func() any {
@@ -151,5 +245,13 @@ func main() {
 
 	mux := app.mount()
 
-	logger.Fatal(app.run(mux))
+	err = app.run(mux)
+
+	// stop the workers and write the impressions still buffered
+	cancel()
+	app.impressions.Wait()
+
+	if err != nil {
+		logger.Fatal(err)
+	}
 }
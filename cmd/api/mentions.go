@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+
+	"github.com/yunsuk-jeung/social/internal/extract"
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+// setPostMentions stores the users mentioned in the post content and
+// notifies the ones that were not mentioned before
+func (app *application) setPostMentions(ctx context.Context, post *store.Post) error {
+	mentions, added, err := app.store.Mentions.SetForPost(ctx, post, extract.Mentions(post.Content))
+	if err != nil {
+		return err
+	}
+	post.Mentions = mentions
+
+	for _, userID := range added {
+		err := app.notify(ctx, &store.Notification{
+			UserID:  userID,
+			ActorID: post.UserID,
+			Type:    store.NotificationMention,
+			PostID:  &post.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setCommentMentions is setPostMentions for comments
+func (app *application) setCommentMentions(ctx context.Context, comment *store.Comment) error {
+	mentions, added, err := app.store.Mentions.SetForComment(ctx, comment, extract.Mentions(comment.Content))
+	if err != nil {
+		return err
+	}
+	comment.Mentions = mentions
+
+	for _, userID := range added {
+		err := app.notify(ctx, &store.Notification{
+			UserID:    userID,
+			ActorID:   comment.UserID,
+			Type:      store.NotificationMention,
+			PostID:    &comment.PostID,
+			CommentID: &comment.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
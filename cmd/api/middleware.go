@@ -20,40 +20,74 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			app.unauthorizedResponse(w, r, fmt.Errorf("authorization header is malformed"))
+		user, err := app.authenticate(r.Context(), authHeader)
+		if err != nil {
+			app.unauthorizedResponse(w, r, err)
 			return
 		}
 
-		token := parts[1]
+		ctx := context.WithValue(r.Context(), userCtx, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		jwtToken, err := app.authenticator.ValidateToken(token)
-		if err != nil {
-			app.unauthorizedResponse(w, r, err)
+// OptionalAuthTokenMiddleware lets anonymous requests through without a user
+// in the context, a token that is sent still has to be valid
+func (app *application) OptionalAuthTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			next.ServeHTTP(w, r)
 			return
 		}
-		claims := jwtToken.Claims.(jwt.MapClaims)
 
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+		user, err := app.authenticate(r.Context(), authHeader)
 		if err != nil {
 			app.unauthorizedResponse(w, r, err)
 			return
 		}
 
-		ctx := r.Context()
+		ctx := context.WithValue(r.Context(), userCtx, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		user, err := app.getUser(ctx, userID)
-		if err != nil {
-			app.unauthorizedResponse(w, r, err)
+// RequireUserMiddleware rejects the anonymous requests let through by
+// OptionalAuthTokenMiddleware
+func (app *application) RequireUserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if getUserFromCtx(r) == nil {
+			app.unauthorizedResponse(w, r, fmt.Errorf("authorization header is missing"))
 			return
 		}
 
-		ctx = context.WithValue(ctx, userCtx, user)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r)
 	})
 }
 
+// authenticate returns the user of the bearer token in authHeader
+func (app *application) authenticate(ctx context.Context, authHeader string) (*store.User, error) {
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, fmt.Errorf("authorization header is malformed")
+	}
+
+	token := parts[1]
+
+	jwtToken, err := app.authenticator.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+	claims := jwtToken.Claims.(jwt.MapClaims)
+
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.getUser(ctx, userID)
+}
+
 func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -131,7 +165,12 @@ func (app *application) checkRolePrecedence(ctx context.Context, user *store.Use
 func (app *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
 	// app.logger.Infow("cache hit", "key", "user", "id", userID)
 	if !app.config.redis.enabled {
-		return app.store.Users.GetByID(ctx, userID)
+		user, err := app.store.Users.GetByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		app.setAvatarURLs(user)
+		return user, nil
 	}
 
 	user, err := app.cacheStorage.Users.Get(ctx, userID)
@@ -145,6 +184,8 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 		if err != nil {
 			return nil, err
 		}
+		// cached without the avatar key, the URL has to be set beforehand
+		app.setAvatarURLs(user)
 		if err := app.cacheStorage.Users.Set(ctx, user); err != nil {
 			return nil, err
 		}
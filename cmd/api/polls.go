@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+// longest a poll can stay open
+const maxPollDuration = time.Hour * 24 * 30
+
+type CreatePollPayload struct {
+	Options  []string  `json:"options" validate:"min=2,max=6,dive,required,max=100"`
+	Multiple bool      `json:"multiple"`
+	ClosesAt time.Time `json:"closes_at" validate:"required"`
+}
+
+type VotePollPayload struct {
+	OptionIDs []int64 `json:"option_ids" validate:"required,min=1,max=6,dive,gt=0"`
+}
+
+func (p *CreatePollPayload) validate() error {
+	now := time.Now()
+
+	if !p.ClosesAt.After(now) {
+		return fmt.Errorf("closes_at must be in the future")
+	}
+
+	if p.ClosesAt.After(now.Add(maxPollDuration)) {
+		return fmt.Errorf("closes_at must be within %d days", int(maxPollDuration.Hours()/24))
+	}
+
+	return nil
+}
+
+// VotePoll godoc
+//
+//	@Summary		Votes on a poll
+//	@Description	Casts the vote of the current user on the poll of a post, a user votes once per poll
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		VotePollPayload	true	"Chosen options"
+//	@Success		200		{object}	store.Poll
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Already voted or poll closed"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/poll/votes [post]
+func (app *application) votePollHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	var payload VotePollPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	poll, err := app.getPoll(ctx, user, post.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	optionIDs := slices.Compact(slices.Sorted(slices.Values(payload.OptionIDs)))
+
+	if !poll.Multiple && len(optionIDs) > 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("this poll allows a single choice"))
+		return
+	}
+
+	for _, id := range optionIDs {
+		if !slices.ContainsFunc(poll.Options, func(o store.PollOption) bool { return o.ID == id }) {
+			app.badRequestResponse(w, r, fmt.Errorf("option %d is not part of this poll", id))
+			return
+		}
+	}
+
+	if err := app.store.Polls.Vote(ctx, poll.ID, user.ID, optionIDs); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, fmt.Errorf("you already voted on this poll"))
+		case errors.Is(err, store.ErrPollClosed):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	poll, err = app.getPoll(ctx, user, post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, poll); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// createPoll attaches the poll in payload to a freshly created post
+func (app *application) createPoll(ctx context.Context, post *store.Post, payload *CreatePollPayload) error {
+	poll := &store.Poll{
+		PostID:   post.ID,
+		Multiple: payload.Multiple,
+		ClosesAt: payload.ClosesAt,
+		Options:  make([]store.PollOption, len(payload.Options)),
+	}
+
+	for i, text := range payload.Options {
+		poll.Options[i] = store.PollOption{Text: text}
+	}
+
+	if err := app.store.Polls.Create(ctx, poll); err != nil {
+		return err
+	}
+
+	post.Poll = poll
+	return nil
+}
+
+func (app *application) getPoll(ctx context.Context, viewer *store.User, postID int64) (*store.Poll, error) {
+	polls, err := app.store.Polls.GetByPostIDs(ctx, viewer.ID, []int64{postID})
+	if err != nil {
+		return nil, err
+	}
+
+	poll, ok := polls[postID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	poll.HideResults()
+	return poll, nil
+}
+
+// withPolls loads the polls of posts as seen by viewer
+func (app *application) withPolls(ctx context.Context, viewer *store.User, posts ...*store.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+
+	// anonymous viewers never voted, they see the results once it closes
+	var viewerID int64
+	if viewer != nil {
+		viewerID = viewer.ID
+	}
+
+	polls, err := app.store.Polls.GetByPostIDs(ctx, viewerID, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		if poll, ok := polls[p.ID]; ok {
+			poll.HideResults()
+			p.Poll = poll
+		}
+	}
+
+	return nil
+}
@@ -2,11 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/yunsuk-jeung/social/internal/markdown"
 	"github.com/yunsuk-jeung/social/internal/store"
 )
 
@@ -14,10 +19,22 @@ type postKey string
 
 const postCtx postKey = "post"
 
+// content formats clients can ask for with ?format=
+const (
+	contentFormatRaw  = "raw"
+	contentFormatHTML = "html"
+)
+
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title        string             `json:"title" validate:"required,max=100"`
+	Content      string             `json:"content" validate:"required,max=1000"`
+	Tags         []string           `json:"tags" validate:"max=10,dive,max=100"`
+	QuotedPostID *int64             `json:"quoted_post_id" validate:"omitempty,gt=0"`
+	Poll         *CreatePollPayload `json:"poll"`
+	// makes the post the next one in the thread of one of the author's posts
+	ContinuesPostID *int64 `json:"continues_post_id" validate:"omitempty,gt=0"`
+	// text search configuration the post is indexed with, english by default
+	Language string `json:"language" validate:"omitempty,search_language"`
 }
 
 type UpdatePostPayload struct {
@@ -36,6 +53,8 @@ type UpdatePostPayload struct {
 //	@Success		201		{object}	store.Post
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error	"Continuing someone else's post"
+//	@Failure		409		{object}	error	"The continued post already has a continuation"
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts [post]
@@ -52,22 +71,127 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if payload.Poll != nil {
+		if err := payload.Poll.validate(); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
 	user := getUserFromCtx(r)
+	ctx := r.Context()
 
-	post := &store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserID:  user.ID,
+	if payload.QuotedPostID != nil {
+		if err := app.checkQuotable(ctx, user, *payload.QuotedPostID); err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequestResponse(w, r, fmt.Errorf("quoted post %d not found", *payload.QuotedPostID))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
 	}
 
-	ctx := r.Context()
+	var threadRootID *int64
+	if payload.ContinuesPostID != nil {
+		previous, err := app.store.Posts.GetByID(ctx, *payload.ContinuesPostID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequestResponse(w, r, fmt.Errorf("continued post %d not found", *payload.ContinuesPostID))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if previous.UserID != user.ID {
+			app.forbiddenResponse(w, r)
+			return
+		}
+
+		threadRootID = previous.ThreadRootID
+		if threadRootID == nil {
+			threadRootID = &previous.ID
+		}
+	}
+
+	html, err := markdown.Render(payload.Content)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	post := &store.Post{
+		Title:        payload.Title,
+		Content:      payload.Content,
+		ContentHTML:  html,
+		Tags:         postTags(payload.Tags, payload.Content),
+		UserID:       user.ID,
+		QuotedPostID: payload.QuotedPostID,
+		Language:     payload.Language,
+
+		ContinuesPostID: payload.ContinuesPostID,
+		ThreadRootID:    threadRootID,
+	}
 
 	if err := app.store.Posts.Create(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, fmt.Errorf("post %d already has a continuation", *payload.ContinuesPostID))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if payload.Poll != nil {
+		if err := app.createPoll(ctx, post, payload.Poll); err != nil {
+			app.rollbackPost(ctx, post)
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.setPostMentions(ctx, post); err != nil {
+		app.rollbackPost(ctx, post)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.setPostLinks(ctx, post); err != nil {
+		app.rollbackPost(ctx, post)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.countTagUsage(ctx, nil, post.Tags); err != nil {
+		app.rollbackPost(ctx, post)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.withQuotedPosts(ctx, user, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	// continuations show in timelines through the head of their thread
+	if post.ThreadRootID == nil {
+		createdAt, err := time.Parse(time.RFC3339, post.CreatedAt)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		app.updateTimelines(timelineJob{
+			action: timelineAddEntry,
+			userID: user.ID,
+			entry:  store.FeedEntry{PostID: post.ID, SortAt: createdAt},
+		})
+	}
+
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -75,23 +199,48 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 
 }
 
+// rollbackPost deletes a post that failed to be created in full, so it is not
+// published without its poll, mentions or links
+func (app *application) rollbackPost(ctx context.Context, post *store.Post) {
+	if _, err := app.store.Posts.Delete(ctx, post.ID); err != nil {
+		app.logger.Errorw("error deleting post", "error", err)
+	}
+}
+
 // GetPost godoc
 //
 //	@Summary		Fetches a post
-//	@Description	Fetches a post by ID
+//	@Description	Fetches a post by ID. The ETag changes with everything in the response, its version part is what If-Match on edits is checked against.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Post ID"
-//	@Success		200	{object}	store.Post
-//	@Failure		404	{object}	error
-//	@Failure		500	{object}	error
+//	@Param			id		path		int		true	"Post ID"
+//	@Param			format	query		string	false	"Content format, raw (default) or html"
+//	@Param			If-None-Match	header	string	false	"ETag of a cached copy"
+//	@Success		200		{object}	store.Post
+//	@Success		304		{string}	string	"Not modified"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [get]
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	format, err := parseContentFormat(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	viewer := getUserFromCtx(r)
+
+	var viewerID int64
+	if viewer != nil {
+		viewerID = viewer.ID
+	}
+
+	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID, viewerID)
 
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -99,12 +248,54 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	post.Comments = comments
+	for i := range post.Comments {
+		app.setAvatarURLs(&post.Comments[i].User)
+	}
 
-	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+	mentions, err := app.store.Mentions.GetByPostID(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	post.Mentions = mentions
+
+	commentMentions, err := app.store.Mentions.GetForComments(r.Context(), post.ID)
+	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	for i := range post.Comments {
+		post.Comments[i].Mentions = commentMentions[post.Comments[i].ID]
+	}
+
+	if err := app.preparePosts(r.Context(), viewer, format, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.recordImpressions(viewer, post)
+
+	// the tag covers the whole body, a new comment or vote changes it
+	body, err := json.Marshal(&envelope{Data: post})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	etag := postBodyETag(post.Version, body)
+	w.Header().Set("ETag", etag)
+	// bookmarks and votes are only filled in for a token
+	w.Header().Set("Vary", "Authorization")
+
+	if match := r.Header.Get("If-None-Match"); match != "" && ifNoneMatch(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
 }
 
 // DeletePost godoc
@@ -131,7 +322,8 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 
 	ctx := r.Context()
 
-	if err := app.store.Posts.Delete(ctx, id); err != nil {
+	promoted, err := app.store.Posts.Delete(ctx, id)
+	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			app.notFoundResponse(w, r, err)
@@ -140,28 +332,57 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 		}
 		return
 	}
+
+	// the timelines of reposters' followers skip it once it is gone
+	app.updateTimelines(timelineJob{
+		action: timelineRemoveEntry,
+		userID: getPostFromCtx(r).UserID,
+		entry:  store.FeedEntry{PostID: id},
+	})
+
+	// the next post of the thread is its head now, timelines only hold heads
+	if promoted != 0 {
+		app.updateTimelines(timelineJob{action: timelineRebuild, userID: getPostFromCtx(r).UserID})
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // UpdatePost godoc
 //
 //	@Summary		Updates a post
-//	@Description	Updates a post by ID
+//	@Description	Updates a post by ID, If-Match must carry the ETag the edit is based on
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
 //	@Param			id		path		int					true	"Post ID"
 //	@Param			payload	body		UpdatePostPayload	true	"Post payload"
+//	@Param			If-Match	header	string	true	"ETag of the post being edited"
 //	@Success		200		{object}	store.Post
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
 //	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Edited concurrently"
+//	@Failure		412		{object}	error	"ETag does not match"
+//	@Failure		428		{object}	error	"If-Match is missing"
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [patch]
 func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
+	match := r.Header.Get("If-Match")
+	if match == "" {
+		app.preconditionRequiredResponse(w, r, errors.New("the If-Match header is required, send the ETag of the post"))
+		return
+	}
+
+	if !ifMatchVersion(match, post.Version) {
+		w.Header().Set("ETag", postETag(post.Version))
+		app.preconditionFailedResponse(w, r, errors.New("the post was modified, fetch it again before editing"))
+		return
+	}
+
 	var payload UpdatePostPayload
 
 	if err := readJSON(w, r, &payload); err != nil {
@@ -175,19 +396,52 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 
 	}
 
+	previousTags := slices.Clone(post.Tags)
+
 	if payload.Content != nil {
+		html, err := markdown.Render(*payload.Content)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		post.Tags = updatedPostTags(post.Tags, post.Content, *payload.Content)
 		post.Content = *payload.Content
+		post.ContentHTML = html
 	}
 
 	if payload.Title != nil {
 		post.Title = *payload.Title
 	}
 
-	if err := app.store.Posts.Update(r.Context(), post); err != nil {
+	ctx := r.Context()
+
+	if err := app.store.Posts.Update(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrEditConflict):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.setPostMentions(ctx, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.setPostLinks(ctx, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.countTagUsage(ctx, previousTags, post.Tags); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", postETag(post.Version))
+
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -218,6 +472,18 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 			}
 			return
 		}
+
+		visible, err := app.canViewPost(ctx, getUserFromCtx(r), post)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !visible {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -227,3 +493,102 @@ func getPostFromCtx(r *http.Request) *store.Post {
 	post, _ := r.Context().Value(postCtx).(*store.Post)
 	return post
 }
+
+func parseContentFormat(r *http.Request) (string, error) {
+	format := r.URL.Query().Get("format")
+
+	switch format {
+	case "", contentFormatRaw:
+		return contentFormatRaw, nil
+	case contentFormatHTML:
+		return contentFormatHTML, nil
+	default:
+		return "", fmt.Errorf("invalid format %q, must be one of raw, html", format)
+	}
+}
+
+// applyContentFormat drops the rendered HTML for raw output and renders posts
+// created before markdown support for html output
+func applyContentFormat(format string, posts ...*store.Post) error {
+	for _, p := range posts {
+		if format == contentFormatRaw {
+			p.ContentHTML = ""
+			continue
+		}
+
+		if p.ContentHTML == "" && p.Content != "" {
+			html, err := markdown.Render(p.Content)
+			if err != nil {
+				return err
+			}
+			p.ContentHTML = html
+		}
+	}
+
+	return nil
+}
+
+// checkQuotable returns ErrNotFound when the post to quote does not exist or
+// is not visible to user
+func (app *application) checkQuotable(ctx context.Context, user *store.User, postID int64) error {
+	quoted, err := app.store.Posts.GetByID(ctx, postID)
+	if err != nil {
+		return err
+	}
+
+	visible, err := app.canViewPost(ctx, user, quoted)
+	if err != nil {
+		return err
+	}
+
+	if !visible {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// preparePosts fills in what is shared by every post response: attachments,
+// quoted posts, the viewer's bookmarks and votes, link previews, author
+// avatars and the content format
+func (app *application) preparePosts(ctx context.Context, viewer *store.User, format string, posts ...*store.Post) error {
+	if err := app.withAttachments(ctx, posts...); err != nil {
+		return err
+	}
+
+	if err := app.withQuotedPosts(ctx, viewer, posts...); err != nil {
+		return err
+	}
+
+	if err := app.withBookmarked(ctx, viewer, posts...); err != nil {
+		return err
+	}
+
+	if err := app.withPolls(ctx, viewer, posts...); err != nil {
+		return err
+	}
+
+	if err := app.withLinkPreviews(ctx, posts...); err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		app.setAvatarURLs(&p.User)
+		if p.QuotedPost != nil {
+			app.setAvatarURLs(&p.QuotedPost.User)
+			if err := applyContentFormat(format, p.QuotedPost); err != nil {
+				return err
+			}
+		}
+	}
+
+	return applyContentFormat(format, posts...)
+}
+
+func feedPosts(feed []store.PostWithMetadata) []*store.Post {
+	posts := make([]*store.Post, len(feed))
+	for i := range feed {
+		posts[i] = &feed[i].Post
+	}
+	return posts
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/yunsuk-jeung/social/internal/media"
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+type UpdateProfilePayload struct {
+	DisplayName *string `json:"display_name" validate:"omitempty,max=50"`
+	Bio         *string `json:"bio" validate:"omitempty,max=300"`
+	Website     *string `json:"website" validate:"omitempty,max=200,http_url"`
+	Location    *string `json:"location" validate:"omitempty,max=100"`
+	IsPrivate   *bool   `json:"is_private"`
+}
+
+// UpdateProfile godoc
+//
+//	@Summary		Updates the profile of the current user
+//	@Description	Updates the public profile fields, fields left out are kept and an empty string clears one. Making a private account public approves its pending follow requests.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateProfilePayload	true	"Profile payload"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateProfilePayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if payload.DisplayName != nil {
+		user.DisplayName = *payload.DisplayName
+	}
+	if payload.Bio != nil {
+		user.Bio = *payload.Bio
+	}
+	if payload.Website != nil {
+		user.Website = *payload.Website
+	}
+	if payload.Location != nil {
+		user.Location = *payload.Location
+	}
+	if payload.IsPrivate != nil {
+		user.IsPrivate = *payload.IsPrivate
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.UpdateProfile(ctx, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type ChangeUsernamePayload struct {
+	Username string `json:"username" validate:"required,max=100,username"`
+}
+
+// ChangeUsername godoc
+//
+//	@Summary		Changes the username of the current user
+//	@Description	Changes the username, at most once per cooldown. The previous username keeps redirecting to the user for a grace period and cannot be registered by anyone else while it is reserved.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ChangeUsernamePayload	true	"Username payload"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error	"Username taken or reserved, or changed too recently"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/username [put]
+func (app *application) changeUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangeUsernamePayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if err := app.store.Users.ChangeUsername(ctx, user.ID, payload.Username, app.config.usernames); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateUsername):
+			app.conflictResponse(w, r, err)
+		case errors.Is(err, store.ErrUsernameCooldown):
+			app.conflictResponse(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user.Username = payload.Username
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UploadAvatar godoc
+//
+//	@Summary		Uploads the avatar of the current user
+//	@Description	Replaces the avatar with an image, it is resized to the smallest configured variant
+//	@Tags			users
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file	formData	file	true	"Image file"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		415		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/avatar [put]
+func (app *application) uploadAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	upload, err := app.readImageUpload(w, r)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrUnsupportedType):
+			app.unsupportedMediaTypeResponse(w, r, err)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	defer upload.Close()
+
+	key := path.Join("avatars", strconv.FormatInt(user.ID, 10), uuid.New().String(), "original"+upload.ext)
+
+	if err := app.media.Save(key, upload); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// avatars are small, the variants are generated right away so the
+	// response already points at the resized image
+	img, err := app.media.Generate(key, nil)
+	if err != nil {
+		app.deleteUpload(key)
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user.AvatarKey = key
+	width := img.Width
+	for _, v := range img.Variants {
+		if v.Width < width {
+			user.AvatarKey = v.Key
+			width = v.Width
+		}
+	}
+
+	ctx := r.Context()
+
+	previous, err := app.store.Users.SetAvatar(ctx, user.ID, user.AvatarKey)
+	if err != nil {
+		app.deleteUpload(key)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if previous != "" {
+		app.deleteUpload(previous)
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.setAvatarURLs(user)
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// invalidateUser drops the cached copy of a user after it changed
+func (app *application) invalidateUser(ctx context.Context, userID int64) error {
+	if !app.config.redis.enabled {
+		return nil
+	}
+
+	return app.cacheStorage.Users.Delete(ctx, userID)
+}
+
+// setAvatarURLs turns the avatar keys of users into URLs. Users read from the
+// cache only carry the URL, it is left as is.
+func (app *application) setAvatarURLs(users ...*store.User) {
+	for _, u := range users {
+		if u.AvatarKey != "" {
+			u.AvatarURL = app.mediaURL(u.AvatarKey)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+const (
+	feedModeChronological = "chronological"
+	feedModeRanked        = "ranked"
+)
+
+type rankingConfig struct {
+	// weights of the signals a ranked feed is scored by
+	recencyWeight     float64
+	engagementWeight  float64
+	affinityWeight    float64
+	tagInterestWeight float64
+	// a post scores half as much for recency every halfLife
+	halfLife time.Duration
+	// posts older than window are not ranked
+	window time.Duration
+	// interests are read from what the user did within interestWindow
+	interestWindow time.Duration
+	// most recent posts ranked per page
+	candidates int
+	// send the explanation of scores to everyone who asks with debug=true,
+	// admins can always ask
+	debug bool
+}
+
+var errRankedQuery = errors.New("sort, search and tags are not supported in ranked mode")
+
+func parseFeedMode(r *http.Request) (string, error) {
+	mode := r.URL.Query().Get("mode")
+
+	switch mode {
+	case "", feedModeChronological:
+		return feedModeChronological, nil
+	case feedModeRanked:
+		return feedModeRanked, nil
+	default:
+		return "", fmt.Errorf("invalid mode %q, must be one of chronological, ranked", mode)
+	}
+}
+
+// explainRanking tells whether scores are explained to user, with debug=true
+// when ranking debug is on or the user is an admin
+func (app *application) explainRanking(r *http.Request, user *store.User) (bool, error) {
+	if r.URL.Query().Get("debug") != "true" {
+		return false, nil
+	}
+
+	if app.config.ranking.debug {
+		return true, nil
+	}
+
+	return app.checkRolePrecedence(r.Context(), user, "admin")
+}
+
+type rankedEntry struct {
+	entry       store.FeedEntry
+	score       float64
+	explanation *store.RankingExplanation
+}
+
+// rankedFeed orders the recent entries of the home timeline of user by
+// score. Scores are computed as of the time of the first page, carried in the
+// cursor, so that pages do not shift as posts age. Posts made while paging
+// show up on the next first page.
+func (app *application) rankedFeed(ctx context.Context, user *store.User, fq store.PaginatedFeedQuery, explain bool) ([]store.PostWithMetadata, *store.FeedCursor, error) {
+	cfg := app.config.ranking
+
+	rankedAt := time.Now().UTC()
+	if fq.Cursor != nil {
+		rankedAt = fq.Cursor.SortAt
+	}
+
+	candidates, err := app.store.Posts.GetFeedCandidates(ctx, user.ID, store.FeedCandidateQuery{
+		Since:         rankedAt.Add(-cfg.window),
+		Until:         rankedAt,
+		InterestSince: rankedAt.Add(-cfg.interestWindow),
+		Limit:         cfg.candidates,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ranked := make([]rankedEntry, 0, len(candidates))
+	for _, c := range candidates {
+		explanation := cfg.score(c, rankedAt)
+		ranked = append(ranked, rankedEntry{entry: c.FeedEntry, score: explanation.Score, explanation: explanation})
+	}
+
+	slices.SortFunc(ranked, func(x, y rankedEntry) int {
+		if c := cmp.Compare(y.score, x.score); c != 0 {
+			return c
+		}
+		return cmp.Compare(y.entry.PostID, x.entry.PostID)
+	})
+
+	// the page starts after the last entry of the previous one
+	if fq.Cursor != nil {
+		cursorScore, cursorID := *fq.Cursor.Score, fq.Cursor.PostID
+		start := slices.IndexFunc(ranked, func(e rankedEntry) bool {
+			return e.score < cursorScore || (e.score == cursorScore && e.entry.PostID < cursorID)
+		})
+		if start < 0 {
+			start = len(ranked)
+		}
+		ranked = ranked[start:]
+	}
+
+	var next *store.FeedCursor
+	if len(ranked) > fq.Limit {
+		ranked = ranked[:fq.Limit]
+		last := ranked[len(ranked)-1]
+		next = &store.FeedCursor{SortAt: rankedAt, PostID: last.entry.PostID, Score: &last.score}
+	}
+
+	entries := make([]store.FeedEntry, len(ranked))
+	explanations := make(map[int64]*store.RankingExplanation, len(ranked))
+	for i, e := range ranked {
+		entries[i] = e.entry
+		explanations[e.entry.PostID] = e.explanation
+	}
+
+	feed, err := app.store.Posts.GetByFeedEntries(ctx, user.ID, entries)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if explain {
+		for i := range feed {
+			feed[i].Ranking = explanations[feed[i].ID]
+		}
+	}
+
+	return feed, next, nil
+}
+
+// score weighs the signals of a candidate ranked at rankedAt. Recency decays
+// from 1, engagement and affinity grow with the log of their counts, and tag
+// interest is the share of the tags of the post the user engaged with.
+func (cfg rankingConfig) score(c store.FeedCandidate, rankedAt time.Time) *store.RankingExplanation {
+	age := max(rankedAt.Sub(c.SortAt), 0)
+
+	recency := 1.0
+	if cfg.halfLife > 0 {
+		recency = math.Exp2(-age.Hours() / cfg.halfLife.Hours())
+	}
+
+	tagInterest := 0.0
+	if c.Tags > 0 {
+		tagInterest = float64(c.MatchedTags) / float64(c.Tags)
+	}
+
+	factors := []store.RankingFactor{
+		{Name: "recency", Value: recency, Weight: cfg.recencyWeight},
+		{Name: "engagement", Value: math.Log1p(float64(c.Comments + c.Reposts + c.Quotes)), Weight: cfg.engagementWeight},
+		{Name: "affinity", Value: math.Log1p(float64(c.Interactions)), Weight: cfg.affinityWeight},
+		{Name: "tag_interest", Value: tagInterest, Weight: cfg.tagInterestWeight},
+	}
+
+	explanation := &store.RankingExplanation{Factors: factors}
+	for i := range factors {
+		factors[i].Contribution = factors[i].Value * factors[i].Weight
+		explanation.Score += factors[i].Contribution
+	}
+
+	return explanation
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+func TestRankingScore(t *testing.T) {
+	cfg := rankingConfig{
+		recencyWeight:     1,
+		engagementWeight:  0.5,
+		affinityWeight:    0.8,
+		tagInterestWeight: 0.6,
+		halfLife:          time.Hour * 6,
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	candidate := func(age time.Duration) store.FeedCandidate {
+		return store.FeedCandidate{FeedEntry: store.FeedEntry{PostID: 1, SortAt: now.Add(-age)}}
+	}
+
+	tests := []struct {
+		name      string
+		candidate store.FeedCandidate
+		want      float64
+	}{
+		{"new", candidate(0), 1},
+		{"one half life", candidate(time.Hour * 6), 0.5},
+		{"from the future", candidate(-time.Hour), 1},
+		{"engaged", func() store.FeedCandidate {
+			c := candidate(0)
+			c.Comments, c.Reposts, c.Quotes = 1, 1, 1
+			return c
+		}(), 1 + 0.5*math.Log(4)},
+		{"affinity and tags", func() store.FeedCandidate {
+			c := candidate(time.Hour * 12)
+			c.Interactions = 3
+			c.Tags, c.MatchedTags = 4, 1
+			return c
+		}(), 0.25 + 0.8*math.Log(4) + 0.6*0.25},
+	}
+
+	for _, tt := range tests {
+		got := cfg.score(tt.candidate, now)
+		if math.Abs(got.Score-tt.want) > 1e-9 {
+			t.Errorf("%s: score = %v, want %v", tt.name, got.Score, tt.want)
+		}
+
+		sum := 0.0
+		for _, f := range got.Factors {
+			sum += f.Contribution
+		}
+		if sum != got.Score {
+			t.Errorf("%s: contributions add up to %v, score is %v", tt.name, sum, got.Score)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+// Repost godoc
+//
+//	@Summary		Reposts a post
+//	@Description	Shares a post with the followers of the current user
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Post reposted"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Post already reposted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [put]
+func (app *application) repostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	repostedAt, err := app.store.Reposts.Create(r.Context(), user.ID, post.ID)
+	if err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.updateTimelines(timelineJob{
+		action: timelineAddEntry,
+		userID: user.ID,
+		entry:  store.FeedEntry{PostID: post.ID, SortAt: repostedAt},
+	})
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Unrepost godoc
+//
+//	@Summary		Undoes a repost
+//	@Description	Removes the repost of a post by the current user
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Repost removed"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [delete]
+func (app *application) unrepostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	if err := app.store.Reposts.Delete(r.Context(), user.ID, post.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// the post may still be in the timelines at an earlier appearance
+	app.updateTimelines(timelineJob{action: timelineRebuild, userID: user.ID})
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// withQuotedPosts embeds the posts quoted by posts. Quoted posts that were
+// deleted or that the viewer cannot see are left out, the quoted_post_id
+// stays so clients can tell the post is unavailable.
+func (app *application) withQuotedPosts(ctx context.Context, viewer *store.User, posts ...*store.Post) error {
+	var ids []int64
+	for _, p := range posts {
+		if p.QuotedPostID != nil {
+			ids = append(ids, *p.QuotedPostID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	quoted, err := app.store.Posts.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		if p.QuotedPostID == nil {
+			continue
+		}
+
+		q, ok := quoted[*p.QuotedPostID]
+		if !ok {
+			continue
+		}
+
+		visible, err := app.canViewPost(ctx, viewer, q)
+		if err != nil {
+			return err
+		}
+
+		if visible {
+			p.QuotedPost = q
+		}
+	}
+
+	return nil
+}
+
+// canViewPost reports whether viewer may see post, see canViewPostsOf
+func (app *application) canViewPost(ctx context.Context, viewer *store.User, post *store.Post) (bool, error) {
+	return app.canViewPostsOf(ctx, viewer, post.UserID)
+}
+
+// canViewPostsOf reports whether viewer may see the posts of userID. Posts are
+// hidden between users who blocked one another, and those of a private
+// account from everyone but its approved followers.
+func (app *application) canViewPostsOf(ctx context.Context, viewer *store.User, userID int64) (bool, error) {
+	if viewer != nil && viewer.ID == userID {
+		return true, nil
+	}
+
+	author, err := app.getUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if viewer == nil {
+		return !author.IsPrivate, nil
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, viewer.ID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if blocked {
+		return false, nil
+	}
+
+	if !author.IsPrivate {
+		return true, nil
+	}
+
+	relationship, err := app.store.Followers.GetRelationship(ctx, viewer.ID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return relationship.YouFollow, nil
+}
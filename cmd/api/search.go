@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+type SearchResults struct {
+	Posts    []store.PostSearchResult    `json:"posts,omitempty"`
+	Comments []store.CommentSearchResult `json:"comments,omitempty"`
+	Users    []store.UserSearchResult    `json:"users,omitempty"`
+}
+
+// Search godoc
+//
+//	@Summary		Searches posts, comments and users
+//	@Description	Full-text search ranked by relevance. Quote words for a phrase, end a word with * for a prefix match, prefix it with - to exclude it and use OR between alternatives. Highlights are HTML with the matches in <mark>.
+//	@Tags			search
+//	@Produce		json
+//	@Param			q		query		string	true	"Search terms"
+//	@Param			type	query		string	false	"Comma separated result types: posts, comments, users (default all)"
+//	@Param			lang	query		string	false	"Text search language of posts, e.g. english or simple, comments are searched as english"
+//	@Param			limit	query		int		false	"Limit per type"
+//	@Param			offset	query		int		false	"Offset per type"
+//	@Param			format	query		string	false	"Content format, raw (default) or html"
+//	@Success		200		{object}	SearchResults
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/search [get]
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	sq := store.SearchQuery{
+		Language: app.config.search.language,
+		Limit:    10,
+		Offset:   0,
+	}
+
+	sq, err := sq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(sq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	format, err := parseContentFormat(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	sq.ViewerID = getUserFromCtx(r).ID
+
+	ctx := r.Context()
+	var results SearchResults
+
+	if sq.Includes(store.SearchTypePosts) {
+		results.Posts, err = app.store.Search.Posts(ctx, sq)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		posts := make([]*store.Post, len(results.Posts))
+		for i := range results.Posts {
+			posts[i] = &results.Posts[i].Post
+		}
+
+		if err := app.preparePosts(ctx, getUserFromCtx(r), format, posts...); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if sq.Includes(store.SearchTypeComments) {
+		results.Comments, err = app.store.Search.Comments(ctx, sq)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		for i := range results.Comments {
+			app.setAvatarURLs(&results.Comments[i].User)
+		}
+	}
+
+	if sq.Includes(store.SearchTypeUsers) {
+		results.Users, err = app.store.Search.Users(ctx, sq)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yunsuk-jeung/social/internal/extract"
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+// windows trending tags can be computed over, ex) tags/trending?window=24h
+var trendingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": time.Hour * 24,
+	"7d":  time.Hour * 24 * 7,
+}
+
+// tag usage buckets are kept for the longest window
+const tagUsageRetention = time.Hour * 24 * 7
+
+// GetTrendingTags godoc
+//
+//	@Summary		Fetches trending tags
+//	@Description	Fetches the most used tags in a sliding window
+//	@Tags			tags
+//	@Produce		json
+//	@Param			window	query		string	false	"Window, one of 1h, 24h (default), 7d"
+//	@Param			limit	query		int		false	"Limit, max 50"
+//	@Success		200		{object}	[]store.TagCount
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/trending [get]
+func (app *application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	window := qs.Get("window")
+	if window == "" {
+		window = "24h"
+	}
+
+	d, ok := trendingWindows[window]
+	if !ok {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid window %q, must be one of 1h, 24h, 7d", window))
+		return
+	}
+
+	limit := 10
+	if l := qs.Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 50 {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid limit %q, must be between 1 and 50", l))
+			return
+		}
+	}
+
+	tags, err := app.store.Tags.GetTrending(r.Context(), d, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetTagPosts godoc
+//
+//	@Summary		Fetches the posts of a tag
+//	@Description	Fetches the posts carrying a tag, newest first
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			format	query		string	false	"Content format, raw (default) or html"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag := extract.NormalizeTag(chi.URLParam(r, "tag"))
+	if tag == "" {
+		app.badRequestResponse(w, r, fmt.Errorf("tag is missing"))
+		return
+	}
+
+	pq := store.PaginatedQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	pq, err := pq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	format, err := parseContentFormat(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	viewer := getUserFromCtx(r)
+
+	feed, err := app.store.Posts.GetByTag(ctx, tag, viewer.ID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.preparePosts(ctx, viewer, format, feedPosts(feed)...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// postTags merges the explicit tags of a post with the hashtags in its content
+func postTags(explicit []string, content string) []string {
+	tags := []string{}
+	for _, t := range slices.Concat(explicit, extract.Hashtags(content)) {
+		t = extract.NormalizeTag(t)
+		if t != "" && !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// updatedPostTags recomputes the tags of a post whose content changed,
+// hashtags that were removed from the content are dropped
+func updatedPostTags(tags []string, oldContent, newContent string) []string {
+	old := extract.Hashtags(oldContent)
+
+	var explicit []string
+	for _, t := range tags {
+		if !slices.Contains(old, t) {
+			explicit = append(explicit, t)
+		}
+	}
+
+	return postTags(explicit, newContent)
+}
+
+// countTagUsage records the tags in added that are not in previous
+func (app *application) countTagUsage(ctx context.Context, previous, added []string) error {
+	var tags []string
+	for _, t := range added {
+		if !slices.Contains(previous, t) {
+			tags = append(tags, t)
+		}
+	}
+
+	return app.store.Tags.IncrementUsage(ctx, tags)
+}
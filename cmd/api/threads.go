@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+)
+
+// GetThread godoc
+//
+//	@Summary		Fetches a thread
+//	@Description	Fetches the whole thread a post is part of, from its head in order
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			format	query		string	false	"Content format, raw (default) or html"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/thread [get]
+func (app *application) getThreadHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	format, err := parseContentFormat(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rootID := post.ID
+	if post.ThreadRootID != nil {
+		rootID = *post.ThreadRootID
+	}
+
+	ctx := r.Context()
+
+	thread, err := app.store.Posts.GetThread(ctx, rootID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.preparePosts(ctx, getUserFromCtx(r), format, feedPosts(thread)...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, thread); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
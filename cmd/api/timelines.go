@@ -0,0 +1,215 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+type timelineConfig struct {
+	// entries kept per home timeline, older pages are read from the database
+	length int
+	// timelines are filled again from the database at least this often, so
+	// that changes that were not fanned out are eventually picked up
+	ttl time.Duration
+	// the posts and reposts of users with this many followers are merged in
+	// on read instead of being fanned out, 0 fans out everyone
+	celebrityFollowers int
+	workers            int
+}
+
+type timelineAction int
+
+const (
+	timelineAddEntry timelineAction = iota
+	timelineRemoveEntry
+	timelineRebuild
+)
+
+// timelineJob applies a change made by userID to the home timelines of their
+// followers and their own
+type timelineJob struct {
+	action timelineAction
+	userID int64
+	entry  store.FeedEntry
+}
+
+// updateTimelines fans a change out in the background, timelines are only
+// kept with redis
+func (app *application) updateTimelines(job timelineJob) {
+	if !app.config.redis.enabled {
+		return
+	}
+
+	app.timelineQueue.Enqueue(job)
+}
+
+// followers read per page while fanning out
+const timelineFanOutPage = 1000
+
+func (app *application) fanOut(ctx context.Context, job timelineJob) error {
+	// the entries of celebrities are merged into the timelines of their
+	// followers on read
+	if job.action != timelineRemoveEntry && app.config.timelines.celebrityFollowers > 0 {
+		celebrity, err := app.store.Followers.HasFollowers(ctx, job.userID, app.config.timelines.celebrityFollowers)
+		if err != nil {
+			return err
+		}
+		if celebrity {
+			return nil
+		}
+	}
+
+	if err := app.applyTimelineJob(ctx, job, []int64{job.userID}); err != nil {
+		return err
+	}
+
+	var after int64
+	for {
+		followers, err := app.store.Followers.GetFollowerIDs(ctx, job.userID, after, timelineFanOutPage)
+		if err != nil {
+			return err
+		}
+
+		if len(followers) == 0 {
+			return nil
+		}
+
+		if err := app.applyTimelineJob(ctx, job, followers); err != nil {
+			return err
+		}
+
+		if len(followers) < timelineFanOutPage {
+			return nil
+		}
+		after = followers[len(followers)-1]
+	}
+}
+
+func (app *application) applyTimelineJob(ctx context.Context, job timelineJob, userIDs []int64) error {
+	switch job.action {
+	case timelineAddEntry:
+		return app.cacheStorage.Timelines.Add(ctx, userIDs, job.entry, app.config.timelines.length)
+	case timelineRemoveEntry:
+		return app.cacheStorage.Timelines.Remove(ctx, userIDs, job.entry.PostID)
+	case timelineRebuild:
+		return app.cacheStorage.Timelines.Delete(ctx, userIDs...)
+	}
+
+	return nil
+}
+
+// invalidateTimelines drops the home timelines of users whose follows or
+// mutes changed, they are filled again on their next read
+func (app *application) invalidateTimelines(ctx context.Context, userIDs ...int64) error {
+	if !app.config.redis.enabled {
+		return nil
+	}
+
+	return app.cacheStorage.Timelines.Delete(ctx, userIDs...)
+}
+
+// homeFeed reads the feed of user from their timeline in redis when it can
+// serve the query, the posts of celebrities are merged in from the database.
+// Filtered, ascending and offset queries, and pages past the end of the
+// timeline, are read from the database.
+func (app *application) homeFeed(ctx context.Context, user *store.User, fq store.PaginatedFeedQuery) ([]store.PostWithMetadata, *store.FeedCursor, error) {
+	if !app.config.redis.enabled || fq.Sort != "desc" || fq.Offset > 0 || fq.Search != "" || len(fq.Tags) > 0 {
+		return app.store.Posts.GetUserFeed(ctx, user.ID, fq)
+	}
+
+	entries, warm, err := app.cacheStorage.Timelines.Get(ctx, user.ID, fq.Cursor, fq.Limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !warm {
+		if err := app.fillTimeline(ctx, user.ID); err != nil {
+			return nil, nil, err
+		}
+
+		entries, _, err = app.cacheStorage.Timelines.Get(ctx, user.ID, fq.Cursor, fq.Limit+1)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// the timeline ends before the page does, it may have been trimmed
+	if len(entries) <= fq.Limit {
+		return app.store.Posts.GetUserFeed(ctx, user.ID, fq)
+	}
+
+	celebrities, err := app.store.Posts.GetFeedEntries(ctx, user.ID, store.FeedEntryQuery{
+		Cursor:             fq.Cursor,
+		Limit:              fq.Limit + 1,
+		CelebrityFollowers: app.config.timelines.celebrityFollowers,
+		Celebrities:        true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries, next := pageFeedEntries(mergeFeedEntries(entries, celebrities), fq.Limit)
+
+	// posts the user may no longer see are left out, the page can come
+	// short of the limit
+	feed, err := app.store.Posts.GetByFeedEntries(ctx, user.ID, entries)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return feed, next, nil
+}
+
+// pageFeedEntries cuts a page of limit entries, the cursor of the next page
+// is nil when there is none
+func pageFeedEntries(entries []store.FeedEntry, limit int) ([]store.FeedEntry, *store.FeedCursor) {
+	if len(entries) <= limit {
+		return entries, nil
+	}
+
+	entries = entries[:limit]
+	last := entries[len(entries)-1]
+
+	return entries, &store.FeedCursor{SortAt: last.SortAt, PostID: last.PostID}
+}
+
+// fillTimeline backfills the timeline of a user from the database, without
+// the posts of celebrities
+func (app *application) fillTimeline(ctx context.Context, userID int64) error {
+	entries, err := app.store.Posts.GetFeedEntries(ctx, userID, store.FeedEntryQuery{
+		Limit:              app.config.timelines.length,
+		CelebrityFollowers: app.config.timelines.celebrityFollowers,
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.cacheStorage.Timelines.Fill(ctx, userID, entries, app.config.timelines.ttl)
+}
+
+// mergeFeedEntries merges two lists of entries ordered newest first, a post
+// in both is kept at its most recent appearance
+func mergeFeedEntries(a, b []store.FeedEntry) []store.FeedEntry {
+	merged := slices.Concat(a, b)
+
+	slices.SortFunc(merged, func(x, y store.FeedEntry) int {
+		if c := y.SortAt.Compare(x.SortAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(y.PostID, x.PostID)
+	})
+
+	seen := make(map[int64]bool, len(merged))
+
+	return slices.DeleteFunc(merged, func(e store.FeedEntry) bool {
+		if seen[e.PostID] {
+			return true
+		}
+		seen[e.PostID] = true
+		return false
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+func TestMergeFeedEntries(t *testing.T) {
+	at := func(sec int) time.Time {
+		return time.Unix(int64(1700000000+sec), 0).UTC()
+	}
+
+	fannedOut := []store.FeedEntry{
+		{PostID: 5, SortAt: at(50)},
+		{PostID: 3, SortAt: at(30)},
+		{PostID: 2, SortAt: at(30)},
+		{PostID: 1, SortAt: at(10)},
+	}
+	celebrities := []store.FeedEntry{
+		{PostID: 7, SortAt: at(40)},
+		{PostID: 4, SortAt: at(30)},
+		// reposted by a celebrity after it was fanned out
+		{PostID: 1, SortAt: at(20)},
+	}
+
+	want := []store.FeedEntry{
+		{PostID: 5, SortAt: at(50)},
+		{PostID: 7, SortAt: at(40)},
+		{PostID: 4, SortAt: at(30)},
+		{PostID: 3, SortAt: at(30)},
+		{PostID: 2, SortAt: at(30)},
+		{PostID: 1, SortAt: at(20)},
+	}
+
+	if got := mergeFeedEntries(fannedOut, celebrities); !slices.Equal(got, want) {
+		t.Errorf("mergeFeedEntries() = %v, want %v", got, want)
+	}
+
+	if got := mergeFeedEntries(nil, celebrities); len(got) != len(celebrities) {
+		t.Errorf("mergeFeedEntries(nil, celebrities) = %v, want %v", got, celebrities)
+	}
+}
+
+func TestPageFeedEntries(t *testing.T) {
+	at := time.Unix(1700000000, 0).UTC()
+	entries := []store.FeedEntry{
+		{PostID: 3, SortAt: at},
+		{PostID: 2, SortAt: at},
+		{PostID: 1, SortAt: at.Add(-time.Second)},
+	}
+
+	page, next := pageFeedEntries(entries, 2)
+	if len(page) != 2 {
+		t.Fatalf("pageFeedEntries(entries, 2) returned %d entries, want 2", len(page))
+	}
+	if next == nil || next.PostID != 2 || !next.SortAt.Equal(at) || next.Score != nil {
+		t.Errorf("pageFeedEntries(entries, 2) cursor = %+v, want post 2 at %v", next, at)
+	}
+
+	page, next = pageFeedEntries(entries, 3)
+	if len(page) != 3 || next != nil {
+		t.Errorf("pageFeedEntries(entries, 3) = %d entries, cursor %+v, want 3 entries and no cursor", len(page), next)
+	}
+}
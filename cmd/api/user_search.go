@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+// suggestions shown while typing an @ handle
+const autocompleteLimit = 5
+
+// SearchUsers godoc
+//
+//	@Summary		Searches people
+//	@Description	Finds users by username or display name, prefix and similar matches, people the current user follows and mutuals rank higher. With mode=autocomplete only username prefixes are matched, fast enough to run on every key stroke.
+//	@Tags			users
+//	@Produce		json
+//	@Param			q		query		string	true	"Username or display name, a leading @ is ignored"
+//	@Param			mode	query		string	false	"autocomplete"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.UserMatch
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/search [get]
+func (app *application) searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	q := store.UserSearchQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	if r.URL.Query().Get("mode") == "autocomplete" {
+		q.Limit = autocompleteLimit
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	q.ViewerID = getUserFromCtx(r).ID
+
+	users, err := app.store.Users.Search(r.Context(), q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range users {
+		app.setAvatarURLs(&users[i].User)
+	}
+
+	// the client asks again for every key stroke, repeated prefixes are
+	// served from its cache
+	if q.Autocomplete {
+		w.Header().Set("Cache-Control", "private, max-age=30")
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
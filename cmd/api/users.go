@@ -3,7 +3,9 @@ package main
 import (
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/yunsuk-jeung/social/internal/store"
@@ -13,22 +15,31 @@ type userKey string
 
 const userCtx userKey = "user"
 
+// UserProfile is a user as shown on their profile page, the relationship
+// is the one to the viewer and is left out for anonymous viewers
+type UserProfile struct {
+	*store.User
+	*store.Relationship
+	store.FollowCounts
+	PinnedPosts []store.PostWithMetadata `json:"pinned_posts"`
+}
+
 // GetUser godoc
 //
 //	@Summary		Fetches a user profile
-//	@Description	Fetches a user profile by ID
+//	@Description	Fetches a user profile by ID, anonymously or with a token for the fields relative to the current user
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path		int	true	"User ID"
-//	@Success		200	{object}	store.User
+//	@Success		200	{object}	UserProfile
 //	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/users/{userID} [get]
 func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
-
 	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
 
 	if err != nil {
@@ -49,7 +60,209 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+	app.writeProfile(w, r, user)
+}
+
+// GetUserByUsername godoc
+//
+//	@Summary		Fetches a user profile by username
+//	@Description	Fetches a user profile by username, regardless of case. A username changed recently redirects to the profile under the new one.
+//	@Tags			users
+//	@Produce		json
+//	@Param			username	path		string	true	"Username"
+//	@Success		200			{object}	UserProfile
+//	@Success		302			{string}	string	"Username changed, see Location"
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/by-username/{username} [get]
+func (app *application) getUserByUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	ctx := r.Context()
+
+	userID, current, err := app.store.Users.LookupUsername(ctx, username)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// old names only redirect for a while and may be taken again, the
+	// redirect must not be cached for good
+	if !strings.EqualFold(current, username) {
+		http.Redirect(w, r, "/v1/users/by-username/"+url.PathEscape(current), http.StatusFound)
+		return
+	}
+
+	user, err := app.getUser(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.writeProfile(w, r, user)
+}
+
+// writeProfile responds with the profile of user as seen by the viewer, if
+// there is one
+func (app *application) writeProfile(w http.ResponseWriter, r *http.Request, user *store.User) {
+	ctx := r.Context()
+	viewer := getUserFromCtx(r)
+
+	if err := app.checkNotBlocked(ctx, viewer, user.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	profile := UserProfile{User: user}
+
+	// the email is only shown to its owner
+	if viewer == nil || viewer.ID != user.ID {
+		public := *user
+		public.Email = ""
+		profile.User = &public
+	}
+
+	if viewer != nil {
+		relationship, err := app.store.Followers.GetRelationship(ctx, viewer.ID, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		profile.Relationship = relationship
+	}
+
+	counts, err := app.store.Followers.GetCounts(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	profile.FollowCounts = *counts
+
+	visible, err := app.canViewPostsOf(ctx, viewer, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the profile of a private account is shown, its posts are not
+	profile.PinnedPosts = []store.PostWithMetadata{}
+	if visible {
+		profile.PinnedPosts, err = app.store.Pins.GetByUserID(ctx, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.preparePosts(ctx, viewer, contentFormatRaw, feedPosts(profile.PinnedPosts)...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetUserPosts godoc
+//
+//	@Summary		Fetches the posts of a user
+//	@Description	Fetches the posts of a user, pinned posts first then newest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			format	query		string	false	"Content format, raw (default) or html"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error	"Private account"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/posts [get]
+func (app *application) getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	pq := store.PaginatedQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	pq, err = pq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	format, err := parseContentFormat(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	viewer := getUserFromCtx(r)
+
+	if err := app.checkNotBlocked(ctx, viewer, userID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	visible, err := app.canViewPostsOf(ctx, viewer, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !visible {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	feed, err := app.store.Posts.GetByUserID(ctx, userID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.preparePosts(ctx, viewer, format, feedPosts(feed)...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
@@ -57,11 +270,12 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 // FollowUser godoc
 //
 //	@Summary		Follows a user
-//	@Description	Fetches a user by ID
+//	@Description	Follows a user, following a private account sends it a follow request instead
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path		int		true	"User ID"
+//	@Success		202	{string}	string	"Follow requested"
 //	@Success		204	{string}	string	"User followed"
 //	@Failure		400	{object}	error	"User payload missing"
 //	@Failure		404	{object}	error	"User not found"
@@ -74,12 +288,40 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
 	}
 
 	ctx := r.Context()
 	log.Println(followerUser.ID)
 	log.Println(followedID)
 
+	// a block is not revealed, the user looks like they do not exist
+	if err := app.checkNotBlocked(ctx, followerUser, followedID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	followed, err := app.getUser(ctx, followedID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if followed.IsPrivate {
+		app.requestFollow(w, r, followerUser, followed)
+		return
+	}
+
 	if err := app.store.Followers.Follow(ctx, followerUser.ID, followedID); err != nil {
 		switch err {
 		case store.ErrConflict:
@@ -91,6 +333,11 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		}
 	}
 
+	if err := app.invalidateTimelines(ctx, followerUser.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -124,6 +371,11 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if err := app.invalidateTimelines(ctx, followerUser.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 	}
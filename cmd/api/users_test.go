@@ -12,7 +12,7 @@ func TestGetUser(t *testing.T) {
 
 	testToken, _ := app.authenticator.GenerateToken(nil)
 
-	t.Run("should not allow unauthenticated requests", func(t *testing.T) {
+	t.Run("should allow anonymous requests", func(t *testing.T) {
 		req, err := http.NewRequest(http.MethodGet, "/v1/users/201", nil)
 		if err != nil {
 			t.Fatal(err)
@@ -20,6 +20,19 @@ func TestGetUser(t *testing.T) {
 
 		rr := exceteRequest(req, mux)
 
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should not allow invalid tokens", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/201", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer invalid")
+
+		rr := exceteRequest(req, mux)
+
 		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
 	})
 
@@ -36,4 +49,15 @@ func TestGetUser(t *testing.T) {
 		checkResponseCode(t, http.StatusOK, rr.Code)
 	})
 
+	t.Run("should fetch users by username", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/by-username/gopher", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := exceteRequest(req, mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+
 }
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS post_attachments (
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    key text NOT NULL,
+    content_type varchar(100) NOT NULL,
+    size bigint NOT NULL DEFAULT 0,
+    width int NOT NULL DEFAULT 0,
+    height int NOT NULL DEFAULT 0,
+    blurhash varchar(100) NOT NULL DEFAULT '',
+    variants jsonb NOT NULL DEFAULT '{}',
+    created_at timestamp (0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_attachments_post_id ON post_attachments (post_id);
@@ -0,0 +1,2 @@
+ALTER TABLE posts
+ADD COLUMN content_html text NOT NULL DEFAULT '';
@@ -0,0 +1,15 @@
+-- hourly usage buckets, trending tags sum the buckets inside a window
+CREATE TABLE IF NOT EXISTS tag_usages (
+    tag varchar(100) NOT NULL,
+    bucket timestamp (0) with time zone NOT NULL,
+    count int NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (tag, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_usages_bucket ON tag_usages (bucket);
+
+UPDATE posts SET tags = ARRAY(
+    SELECT DISTINCT lower(t) FROM unnest(tags) AS t
+)
+WHERE tags IS NOT NULL;
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS polls (
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL UNIQUE,
+    multiple boolean NOT NULL DEFAULT FALSE,
+    closes_at timestamp (0) with time zone NOT NULL,
+    created_at timestamp (0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS poll_options (
+    id bigserial PRIMARY KEY,
+    poll_id bigint NOT NULL,
+    position int NOT NULL,
+    text varchar(100) NOT NULL,
+
+    UNIQUE (poll_id, id),
+    FOREIGN KEY (poll_id) REFERENCES polls (id) ON DELETE CASCADE
+);
+
+-- one ballot per user and poll, a ballot holds one vote per chosen option
+CREATE TABLE IF NOT EXISTS poll_ballots (
+    poll_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    created_at timestamp (0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (poll_id, user_id),
+    FOREIGN KEY (poll_id) REFERENCES polls (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS poll_votes (
+    poll_id bigint NOT NULL,
+    option_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+
+    PRIMARY KEY (option_id, user_id),
+    FOREIGN KEY (poll_id, option_id) REFERENCES poll_options (poll_id, id) ON DELETE CASCADE,
+    FOREIGN KEY (poll_id, user_id) REFERENCES poll_ballots (poll_id, user_id) ON DELETE CASCADE
+);
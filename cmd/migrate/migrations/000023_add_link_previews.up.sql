@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS link_previews (
+    url text PRIMARY KEY,
+    title text NOT NULL DEFAULT '',
+    description text NOT NULL DEFAULT '',
+    image_url text NOT NULL DEFAULT '',
+    site_name text NOT NULL DEFAULT '',
+    -- failed fetches are cached too so dead links are not retried on every edit
+    failed boolean NOT NULL DEFAULT false,
+    fetched_at timestamp (0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS post_links (
+    post_id bigint NOT NULL,
+    url text NOT NULL,
+    position int NOT NULL,
+
+    PRIMARY KEY (post_id, url),
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
@@ -0,0 +1,33 @@
+-- one row per viewer per post per hour, the primary key does the dedup
+CREATE TABLE IF NOT EXISTS post_impressions (
+    post_id bigint NOT NULL,
+    viewer_id bigint NOT NULL,
+    window_start timestamp (0) with time zone NOT NULL,
+
+    PRIMARY KEY (post_id, viewer_id, window_start),
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    FOREIGN KEY (viewer_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- impressions are rolled up into hourly counts once the hour is over, and
+-- hourly counts into daily ones once they are past the hourly retention
+CREATE TABLE IF NOT EXISTS post_views_hourly (
+    post_id bigint NOT NULL,
+    bucket timestamp (0) with time zone NOT NULL,
+    views bigint NOT NULL,
+
+    PRIMARY KEY (post_id, bucket),
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS post_views_daily (
+    post_id bigint NOT NULL,
+    bucket timestamp (0) with time zone NOT NULL,
+    views bigint NOT NULL,
+
+    PRIMARY KEY (post_id, bucket),
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_id_created_at ON comments (post_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_followers_user_id_created_at ON followers (user_id, created_at);
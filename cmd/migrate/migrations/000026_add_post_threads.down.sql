@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_posts_thread_root_id;
+DROP INDEX IF EXISTS idx_posts_continues_post_id;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS thread_root_id,
+DROP COLUMN IF EXISTS continues_post_id;
@@ -0,0 +1,11 @@
+-- a continuation points at the post it follows and at the head of its
+-- thread, a post can be continued only once so a thread is a single chain
+ALTER TABLE posts
+ADD COLUMN continues_post_id bigint REFERENCES posts (id) ON DELETE SET NULL,
+ADD COLUMN thread_root_id bigint REFERENCES posts (id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_continues_post_id ON posts (continues_post_id)
+WHERE continues_post_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_thread_root_id ON posts (thread_root_id)
+WHERE thread_root_id IS NOT NULL;
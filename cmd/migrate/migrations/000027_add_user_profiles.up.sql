@@ -0,0 +1,6 @@
+ALTER TABLE users
+ADD COLUMN display_name varchar(50) NOT NULL DEFAULT '',
+ADD COLUMN bio varchar(300) NOT NULL DEFAULT '',
+ADD COLUMN website varchar(200) NOT NULL DEFAULT '',
+ADD COLUMN location varchar(100) NOT NULL DEFAULT '',
+ADD COLUMN avatar_key text NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS user_suggestions (
+    user_id bigint NOT NULL,
+    suggested_id bigint NOT NULL,
+    score double precision NOT NULL,
+    reason varchar(50) NOT NULL,
+    created_at timestamp (0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, suggested_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (suggested_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_suggestions_score ON user_suggestions (user_id, score DESC);
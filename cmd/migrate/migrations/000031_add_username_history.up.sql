@@ -0,0 +1,15 @@
+-- usernames a user left behind, they keep pointing to the user until
+-- redirect_until and nobody else can take them until reserved_until
+CREATE TABLE IF NOT EXISTS username_history (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    username varchar(255) NOT NULL,
+    changed_at timestamp (0) with time zone NOT NULL DEFAULT NOW(),
+    redirect_until timestamp (0) with time zone NOT NULL,
+    reserved_until timestamp (0) with time zone NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_username_lower ON username_history (lower(username));
+CREATE INDEX IF NOT EXISTS idx_username_history_user_id ON username_history (user_id, changed_at DESC);
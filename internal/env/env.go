@@ -39,3 +39,16 @@ func GetBool(key string, fallback bool) bool {
 	}
 	return valAsBool
 }
+
+func GetFloat(key string, fallback float64) float64 {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	valAsFloat, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return fallback
+	}
+	return valAsFloat
+}
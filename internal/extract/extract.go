@@ -0,0 +1,111 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+// a mention is an @ that does not follow a word character, so emails and
+// things like a@b are not picked up
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@])@(\w{1,100})`)
+
+// a username is what a mention can pick up
+var usernameRegex = regexp.MustCompile(`^\w{1,100}$`)
+
+// hashtags follow the same rule and need at least one letter, so #1 is not a tag
+var (
+	hashtagRegex = regexp.MustCompile(`(?:^|[^\w#&])#(\w{1,100})`)
+	letterRegex  = regexp.MustCompile(`[^\d_]`)
+)
+
+// links are http(s) URLs up to the next whitespace, trailing punctuation is
+// trimmed so "see https://go.dev." links to https://go.dev
+var urlRegex = regexp.MustCompile(`https?://[^\s<>"'\x60]+`)
+
+// IsUsername reports whether s can be used as a username
+func IsUsername(s string) bool {
+	return usernameRegex.MatchString(s)
+}
+
+// Mentions returns the distinct usernames mentioned in s, in order of first
+// appearance. Matching is case insensitive, the first spelling is kept.
+func Mentions(s string) []string {
+	var (
+		mentions []string
+		seen     = make(map[string]bool)
+	)
+
+	for _, m := range mentionRegex.FindAllStringSubmatch(s, -1) {
+		key := strings.ToLower(m[1])
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		mentions = append(mentions, m[1])
+	}
+
+	return mentions
+}
+
+// Hashtags returns the distinct normalized hashtags in s, in order of first
+// appearance
+func Hashtags(s string) []string {
+	var (
+		tags []string
+		seen = make(map[string]bool)
+	)
+
+	for _, m := range hashtagRegex.FindAllStringSubmatch(s, -1) {
+		if !letterRegex.MatchString(m[1]) {
+			continue
+		}
+
+		tag := NormalizeTag(m[1])
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// NormalizeTag lower cases a tag and strips a leading #
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// URLs returns the distinct http(s) links in s, in order of first appearance
+func URLs(s string) []string {
+	var (
+		urls []string
+		seen = make(map[string]bool)
+	)
+
+	for _, u := range urlRegex.FindAllString(s, -1) {
+		u = trimURL(u)
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+
+	return urls
+}
+
+// trimURL drops trailing punctuation, a closing paren is only kept when it
+// balances one in the URL, as in /wiki/Go_(programming_language)
+func trimURL(u string) string {
+	for {
+		trimmed := strings.TrimRight(u, ".,:;!?'\"*_~")
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+			trimmed = strings.TrimSuffix(trimmed, ")")
+		}
+		if trimmed == u {
+			return u
+		}
+		u = trimmed
+	}
+}
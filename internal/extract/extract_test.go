@@ -0,0 +1,80 @@
+package extract
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"hello @alice and @bob", []string{"alice", "bob"}},
+		{"@alice at the start", []string{"alice"}},
+		{"dupes @alice @Alice @alice", []string{"alice"}},
+		{"(@carol), @dave.", []string{"carol", "dave"}},
+		{"mail me at bob@example.com", nil},
+		{"@@eve is not a mention", nil},
+		{"no mentions here", nil},
+	}
+
+	for _, tt := range tests {
+		if got := Mentions(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("Mentions(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsUsername(t *testing.T) {
+	for _, s := range []string{"alice", "Bob_42", "_"} {
+		if !IsUsername(s) {
+			t.Errorf("IsUsername(%q) = false, want true", s)
+		}
+	}
+
+	for _, s := range []string{"", "bob smith", "@alice", "eve.b", "a-b"} {
+		if IsUsername(s) {
+			t.Errorf("IsUsername(%q) = true, want false", s)
+		}
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"learning #Go and #golang", []string{"go", "golang"}},
+		{"#go #GO #Go", []string{"go"}},
+		{"#1 is not a tag but #web3 is", []string{"web3"}},
+		{"anchors like page#section are ignored", nil},
+		{"html entities &#39; are ignored", nil},
+		{"(#gophers)", []string{"gophers"}},
+	}
+
+	for _, tt := range tests {
+		if got := Hashtags(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("Hashtags(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestURLs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"see https://go.dev.", []string{"https://go.dev"}},
+		{"(https://example.com/a?b=c)", []string{"https://example.com/a?b=c"}},
+		{"https://en.wikipedia.org/wiki/Go_(programming_language)!", []string{"https://en.wikipedia.org/wiki/Go_(programming_language)"}},
+		{"http://a.io http://a.io, http://b.io", []string{"http://a.io", "http://b.io"}},
+		{"ftp://example.com and example.com are not links", nil},
+	}
+
+	for _, tt := range tests {
+		if got := URLs(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("URLs(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
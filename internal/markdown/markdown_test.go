@@ -0,0 +1,61 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "formatting",
+			src:      "**bold** _em_ ~~del~~ `code`",
+			contains: []string{"<strong>bold</strong>", "<em>em</em>", "<del>del</del>", "<code>code</code>"},
+		},
+		{
+			name:     "links get rel attributes",
+			src:      "[go](https://go.dev)",
+			contains: []string{`href="https://go.dev"`, `rel="nofollow noreferrer noopener"`},
+		},
+		{
+			name:     "script tags are stripped",
+			src:      "hi <script>alert(1)</script>",
+			excludes: []string{"<script"},
+		},
+		{
+			name:     "javascript links are stripped",
+			src:      "[click](javascript:alert(1))",
+			excludes: []string{"javascript:"},
+		},
+		{
+			name:     "images are not allowed",
+			src:      "![x](https://example.com/x.png)",
+			excludes: []string{"<img"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := Render(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, s := range tt.contains {
+				if !strings.Contains(html, s) {
+					t.Errorf("expected %q in %q", s, html)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(html, s) {
+					t.Errorf("did not expect %q in %q", s, html)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrTooLarge        = errors.New("image dimensions are too large")
+	ErrInvalidImage    = errors.New("image could not be decoded")
+)
+
+// allowed content types and the extension the original is stored with
+var allowedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+type Variant struct {
+	Name  string
+	Width int
+}
+
+// ParseVariants parses a "name:width,name:width" list, ex) thumbnail:160,medium:640
+func ParseVariants(s string) ([]Variant, error) {
+	var variants []Variant
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, width, ok := strings.Cut(part, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid variant %q", part)
+		}
+
+		w, err := strconv.Atoi(width)
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("invalid variant width %q", part)
+		}
+
+		variants = append(variants, Variant{Name: name, Width: w})
+	}
+
+	return variants, nil
+}
+
+func ExtensionFor(contentType string) (string, error) {
+	ext, ok := allowedTypes[contentType]
+	if !ok {
+		return "", ErrUnsupportedType
+	}
+	return ext, nil
+}
@@ -0,0 +1,226 @@
+package media
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/buckket/go-blurhash"
+	"golang.org/x/image/draw"
+)
+
+const (
+	jpegQuality    = 85
+	blurHashWidth  = 32
+	blurHashXComps = 4
+	blurHashYComps = 3
+)
+
+type Processor struct {
+	dir      string
+	variants []Variant
+	// images with more pixels are not decoded
+	maxPixels int64
+}
+
+type Image struct {
+	Width    int
+	Height   int
+	BlurHash string
+	Variants map[string]VariantImage
+}
+
+type VariantImage struct {
+	Key    string
+	Width  int
+	Height int
+}
+
+func NewProcessor(dir string, variants []Variant, maxPixels int64) *Processor {
+	return &Processor{dir: dir, variants: variants, maxPixels: maxPixels}
+}
+
+func (p *Processor) Dir() string {
+	return p.dir
+}
+
+// Save writes the original upload under key, relative to the media dir
+func (p *Processor) Save(key string, r io.Reader) error {
+	path := filepath.Join(p.dir, filepath.FromSlash(key))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+
+	return f.Close()
+}
+
+// Delete removes the upload stored under key along with its variants, which
+// share its directory
+func (p *Processor) Delete(key string) error {
+	dir := filepath.Dir(filepath.Join(p.dir, filepath.FromSlash(key)))
+
+	rel, err := filepath.Rel(p.dir, dir)
+	if err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return fmt.Errorf("invalid media key %q", key)
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// Missing reports the configured variants that are not present in existing
+// or whose file has gone missing from disk.
+func (p *Processor) Missing(existing map[string]VariantImage) []Variant {
+	var missing []Variant
+
+	for _, v := range p.variants {
+		vi, ok := existing[v.Name]
+		if !ok || !p.exists(vi.Key) {
+			missing = append(missing, v)
+		}
+	}
+
+	return missing
+}
+
+// CheckDimensions reads the header of the image in r, it fails with
+// ErrInvalidImage when it cannot be decoded and ErrTooLarge when it is over
+// the pixel limit. A small file can declare huge dimensions, they are checked
+// before the pixels are allocated.
+func (p *Processor) CheckDimensions(r io.Reader) error {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	if p.maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > p.maxPixels {
+		return ErrTooLarge
+	}
+
+	return nil
+}
+
+// Generate decodes the original stored under key and writes every missing
+// variant next to it as JPEG. Variants already in existing are kept. Images
+// over the pixel limit fail with ErrTooLarge before they are decoded.
+func (p *Processor) Generate(key string, existing map[string]VariantImage) (*Image, error) {
+	f, err := os.Open(filepath.Join(p.dir, filepath.FromSlash(key)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := p.CheckDimensions(f); err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	bounds := src.Bounds()
+	if bounds.Empty() {
+		return nil, ErrUnsupportedType
+	}
+
+	img := &Image{
+		Width:    bounds.Dx(),
+		Height:   bounds.Dy(),
+		Variants: make(map[string]VariantImage, len(p.variants)),
+	}
+
+	// blurhash cost grows with the pixel count, a tiny copy is good enough
+	hash, err := blurhash.Encode(blurHashXComps, blurHashYComps, resize(src, blurHashWidth))
+	if err != nil {
+		return nil, err
+	}
+	img.BlurHash = hash
+
+	missing := p.Missing(existing)
+	for name, vi := range existing {
+		img.Variants[name] = vi
+	}
+
+	dir := filepath.Dir(filepath.FromSlash(key))
+	for _, v := range missing {
+		dst := resize(src, v.Width)
+		vkey := filepath.ToSlash(filepath.Join(dir, v.Name+".jpg"))
+
+		if err := p.writeJPEG(vkey, dst); err != nil {
+			return nil, err
+		}
+
+		img.Variants[v.Name] = VariantImage{
+			Key:    vkey,
+			Width:  dst.Bounds().Dx(),
+			Height: dst.Bounds().Dy(),
+		}
+	}
+
+	return img, nil
+}
+
+func (p *Processor) writeJPEG(key string, img image.Image) error {
+	path := filepath.Join(p.dir, filepath.FromSlash(key))
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+
+	return f.Close()
+}
+
+func (p *Processor) exists(key string) bool {
+	if key == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(p.dir, filepath.FromSlash(key)))
+	return err == nil
+}
+
+// resize scales src down to width keeping the aspect ratio, images that are
+// already narrower are copied as is
+func resize(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	if b.Dx() <= width {
+		width = b.Dx()
+	}
+
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+
+	return dst
+}
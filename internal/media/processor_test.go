@@ -0,0 +1,111 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	variants := []Variant{{Name: "thumbnail", Width: 40}, {Name: "medium", Width: 400}}
+	p := NewProcessor(t.TempDir(), variants, 0)
+
+	src := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for x := range 200 {
+		for y := range 100 {
+			src.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	key := "attachments/1/abc/original.png"
+	if err := p.Save(key, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if missing := p.Missing(nil); len(missing) != 2 {
+		t.Fatalf("expected 2 missing variants, got %d", len(missing))
+	}
+
+	img, err := p.Generate(key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Width != 200 || img.Height != 100 {
+		t.Errorf("expected 200x100, got %dx%d", img.Width, img.Height)
+	}
+
+	if img.BlurHash == "" {
+		t.Error("expected a blurhash")
+	}
+
+	thumb := img.Variants["thumbnail"]
+	if thumb.Width != 40 || thumb.Height != 20 {
+		t.Errorf("expected thumbnail 40x20, got %dx%d", thumb.Width, thumb.Height)
+	}
+
+	// never upscale
+	medium := img.Variants["medium"]
+	if medium.Width != 200 || medium.Height != 100 {
+		t.Errorf("expected medium 200x100, got %dx%d", medium.Width, medium.Height)
+	}
+
+	if missing := p.Missing(img.Variants); len(missing) != 0 {
+		t.Errorf("expected no missing variants, got %v", missing)
+	}
+}
+
+func TestGenerateTooLarge(t *testing.T) {
+	p := NewProcessor(t.TempDir(), []Variant{{Name: "thumbnail", Width: 40}}, 100*100-1)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 100, 100))); err != nil {
+		t.Fatal(err)
+	}
+
+	key := "attachments/1/abc/original.png"
+	if err := p.Save(key, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.Generate(key, nil); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+
+	if err := p.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.exists(key) {
+		t.Error("expected the upload to be deleted")
+	}
+
+	if err := p.Delete("original.png"); err == nil {
+		t.Error("expected an error deleting the media dir")
+	}
+}
+
+func TestParseVariants(t *testing.T) {
+	variants, err := ParseVariants("thumbnail:160, medium:640")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(variants) != 2 || variants[1] != (Variant{Name: "medium", Width: 640}) {
+		t.Errorf("unexpected variants %v", variants)
+	}
+
+	for _, s := range []string{"thumbnail", "thumbnail:0", ":100", "medium:abc"} {
+		if _, err := ParseVariants(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package search
+
+import (
+	"html"
+	"slices"
+	"strings"
+)
+
+// Postgres text search configurations that ship with every install
+var Languages = []string{
+	"simple", "arabic", "danish", "dutch", "english", "finnish", "french",
+	"german", "greek", "hungarian", "indonesian", "italian", "norwegian",
+	"portuguese", "romanian", "russian", "spanish", "swedish", "turkish",
+}
+
+func IsLanguage(s string) bool {
+	return slices.Contains(Languages, s)
+}
+
+// ts_headline marks matches with control characters that cannot show up in
+// user content, Highlight escapes the rest and turns them into <mark> tags
+const (
+	startSel = "\x02"
+	stopSel  = "\x03"
+)
+
+// HeadlineOptions are the ts_headline options to use with Highlight
+const HeadlineOptions = "StartSel=" + startSel + ", StopSel=" + stopSel + ", MaxFragments=2, MaxWords=25, MinWords=10, FragmentDelimiter=\" … \""
+
+// Highlight returns a ts_headline result as HTML safe to render, with the
+// matches wrapped in <mark>
+func Highlight(headline string) string {
+	return strings.NewReplacer(
+		startSel, "<mark>",
+		stopSel, "</mark>",
+	).Replace(html.EscapeString(headline))
+}
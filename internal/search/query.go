@@ -0,0 +1,119 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TSQuery turns user input into the text of a Postgres tsquery, to be passed
+// to to_tsquery so the words are normalized with the search language.
+//
+//	go gopher      both words
+//	"go gopher"    the phrase
+//	gophe*         words starting with gophe
+//	go -rust       go but not rust
+//	go OR rust     either word
+//
+// Anything but letters and digits is dropped from the words, so the result
+// is always a valid tsquery. It is empty when the input has no words.
+func TSQuery(input string) string {
+	var (
+		b      strings.Builder
+		pendOr bool
+	)
+
+	for _, tok := range tokenize(input) {
+		if !tok.quoted && tok.text == "OR" {
+			pendOr = b.Len() > 0
+			continue
+		}
+
+		negate := false
+		text := tok.text
+		if !tok.quoted && strings.HasPrefix(text, "-") {
+			negate = true
+			text = text[1:]
+		}
+
+		term := phrase(text)
+		if term == "" {
+			continue
+		}
+
+		if b.Len() > 0 {
+			if pendOr {
+				b.WriteString(" | ")
+			} else {
+				b.WriteString(" & ")
+			}
+		}
+		pendOr = false
+
+		if negate {
+			b.WriteString("!")
+		}
+		b.WriteString("(" + term + ")")
+	}
+
+	return b.String()
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+// tokenize splits on whitespace, text between double quotes is kept together
+func tokenize(input string) []token {
+	var (
+		tokens []token
+		cur    strings.Builder
+		quoted bool
+	)
+
+	flush := func(q bool) {
+		if cur.Len() > 0 {
+			tokens = append(tokens, token{text: cur.String(), quoted: q})
+			cur.Reset()
+		}
+	}
+
+	for _, r := range input {
+		switch {
+		case r == '"':
+			flush(quoted)
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			flush(false)
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush(quoted)
+
+	return tokens
+}
+
+// phrase joins the words of s with the followed-by operator, a trailing *
+// on a word makes it a prefix match
+func phrase(s string) string {
+	var words []string
+
+	for _, field := range strings.Fields(s) {
+		prefix := strings.HasSuffix(field, "*")
+
+		parts := strings.FieldsFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(parts) == 0 {
+			continue
+		}
+
+		if prefix {
+			parts[len(parts)-1] += ":*"
+		}
+		words = append(words, parts...)
+	}
+
+	return strings.Join(words, " <-> ")
+}
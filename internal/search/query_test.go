@@ -0,0 +1,38 @@
+package search
+
+import "testing"
+
+func TestTSQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"go gopher", "(go) & (gopher)"},
+		{`"go gopher" rocks`, "(go <-> gopher) & (rocks)"},
+		{"gophe*", "(gophe:*)"},
+		{`"hello wor*"`, "(hello <-> wor:*)"},
+		{"go -rust", "(go) & !(rust)"},
+		{"go OR rust", "(go) | (rust)"},
+		{"OR go", "(go)"},
+		{"e-mail", "(e <-> mail)"},
+		{"it's & (drop) | !me:*", "(it <-> s) & (drop) & (me:*)"},
+		{`"unterminated phrase`, "(unterminated <-> phrase)"},
+		{"!!! ---", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := TSQuery(tt.in); got != tt.want {
+			t.Errorf("TSQuery(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	in := "<b>" + startSel + "gopher" + stopSel + "</b> & co"
+	want := "&lt;b&gt;<mark>gopher</mark>&lt;/b&gt; &amp; co"
+
+	if got := Highlight(in); got != want {
+		t.Errorf("Highlight(%q) = %q, want %q", in, got, want)
+	}
+}
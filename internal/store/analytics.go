@@ -0,0 +1,277 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// views are deduplicated per viewer within this window, the rollup relies on
+// it being an hour
+const ImpressionWindow = time.Hour
+
+// analytics intervals
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+)
+
+type Impression struct {
+	PostID   int64
+	ViewerID int64
+	Window   time.Time
+}
+
+// NewImpression is an impression of postID by viewerID now
+func NewImpression(postID, viewerID int64) Impression {
+	return Impression{
+		PostID:   postID,
+		ViewerID: viewerID,
+		Window:   time.Now().UTC().Truncate(ImpressionWindow),
+	}
+}
+
+type AnalyticsQuery struct {
+	Interval string    `json:"interval"`
+	Since    time.Time `json:"since"`
+}
+
+type AnalyticsBucket struct {
+	Bucket       time.Time `json:"bucket"`
+	Views        int64     `json:"views"`
+	Reactions    int64     `json:"reactions"`
+	Comments     int64     `json:"comments"`
+	NewFollowers int64     `json:"new_followers"`
+}
+
+type PostViews struct {
+	PostID int64  `json:"post_id"`
+	Title  string `json:"title"`
+	Views  int64  `json:"views"`
+}
+
+type Analytics struct {
+	Interval  string            `json:"interval"`
+	Since     time.Time         `json:"since"`
+	Followers int64             `json:"followers"`
+	Totals    AnalyticsBucket   `json:"totals"`
+	Series    []AnalyticsBucket `json:"series"`
+	TopPosts  []PostViews       `json:"top_posts"`
+}
+
+type AnalyticsStore struct {
+	db *sql.DB
+}
+
+// RecordImpressions stores a batch of impressions, the ones already recorded
+// for the same window are ignored
+func (s *AnalyticsStore) RecordImpressions(ctx context.Context, impressions []Impression) error {
+	if len(impressions) == 0 {
+		return nil
+	}
+
+	var (
+		postIDs   = make([]int64, len(impressions))
+		viewerIDs = make([]int64, len(impressions))
+		windows   = make([]string, len(impressions))
+	)
+	for i, imp := range impressions {
+		postIDs[i] = imp.PostID
+		viewerIDs[i] = imp.ViewerID
+		windows[i] = imp.Window.Format(time.RFC3339)
+	}
+
+	// posts deleted since they were viewed are skipped
+	query := `
+		INSERT INTO post_impressions (post_id, viewer_id, window_start)
+		SELECT i.post_id, i.viewer_id, i.window_start
+		FROM unnest($1::bigint[], $2::bigint[], $3::timestamptz[]) AS i(post_id, viewer_id, window_start)
+		INNER JOIN posts AS p ON p.id = i.post_id
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	_, err := s.db.ExecContext(ctx, query, pq.Array(postIDs), pq.Array(viewerIDs), pq.Array(windows))
+	return err
+}
+
+// Rollup moves the impressions of past hours into hourly counts and the
+// hourly counts older than hourlyRetention into daily ones
+func (s *AnalyticsStore) Rollup(ctx context.Context, hourlyRetention time.Duration) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancle()
+
+		hourly := `
+			WITH moved AS (
+				DELETE FROM post_impressions
+				WHERE window_start < date_trunc('hour', NOW())
+				RETURNING post_id, window_start
+			)
+			INSERT INTO post_views_hourly (post_id, bucket, views)
+			SELECT post_id, date_trunc('hour', window_start), count(*)
+			FROM moved
+			GROUP BY 1, 2
+			ON CONFLICT (post_id, bucket) DO UPDATE
+			SET views = post_views_hourly.views + EXCLUDED.views
+		`
+
+		if _, err := tx.ExecContext(ctx, hourly); err != nil {
+			return err
+		}
+
+		daily := `
+			WITH moved AS (
+				DELETE FROM post_views_hourly
+				WHERE bucket < date_trunc('day', NOW() - $1 * interval '1 second')
+				RETURNING post_id, bucket, views
+			)
+			INSERT INTO post_views_daily (post_id, bucket, views)
+			SELECT post_id, date_trunc('day', bucket), sum(views)
+			FROM moved
+			GROUP BY 1, 2
+			ON CONFLICT (post_id, bucket) DO UPDATE
+			SET views = post_views_daily.views + EXCLUDED.views
+		`
+
+		_, err := tx.ExecContext(ctx, daily, hourlyRetention.Seconds())
+		return err
+	})
+}
+
+// views of the posts of $1 since $3, bucketed by $2, from every stage of the
+// rollup
+const postViewsSince = `
+	SELECT v.post_id, date_trunc($2, v.bucket) AS bucket, v.views
+	FROM post_views_daily AS v
+	INNER JOIN posts AS p ON p.id = v.post_id
+	WHERE p.user_id = $1 AND v.bucket >= date_trunc('day', $3::timestamptz)
+	UNION ALL
+	SELECT v.post_id, date_trunc($2, v.bucket), v.views
+	FROM post_views_hourly AS v
+	INNER JOIN posts AS p ON p.id = v.post_id
+	WHERE p.user_id = $1 AND v.bucket >= $3
+	UNION ALL
+	SELECT i.post_id, date_trunc($2, i.window_start), 1
+	FROM post_impressions AS i
+	INNER JOIN posts AS p ON p.id = i.post_id
+	WHERE p.user_id = $1 AND i.window_start >= $3
+`
+
+// GetByUserID sums up the audience of a user: views of their posts,
+// reactions (reposts and quotes), comments by others and new followers
+func (s *AnalyticsStore) GetByUserID(ctx context.Context, userID int64, q AnalyticsQuery) (*Analytics, error) {
+	a := &Analytics{
+		Interval: q.Interval,
+		Since:    q.Since,
+		Series:   []AnalyticsBucket{},
+		TopPosts: []PostViews{},
+	}
+
+	series := `
+		WITH buckets AS (
+			SELECT generate_series(date_trunc($2, $3::timestamptz), date_trunc($2, NOW()), ('1 ' || $2)::interval) AS bucket
+		), views AS (
+			SELECT bucket, sum(views) AS n FROM (` + postViewsSince + `) AS v GROUP BY bucket
+		), reactions AS (
+			SELECT date_trunc($2, e.created_at) AS bucket, count(*) AS n
+			FROM (
+				SELECT r.created_at
+				FROM reposts AS r
+				INNER JOIN posts AS p ON p.id = r.post_id
+				WHERE p.user_id = $1 AND r.user_id <> $1 AND r.created_at >= $3
+				UNION ALL
+				SELECT q.created_at
+				FROM posts AS q
+				INNER JOIN posts AS p ON p.id = q.quoted_post_id
+				WHERE p.user_id = $1 AND q.user_id <> $1 AND q.created_at >= $3
+			) AS e
+			GROUP BY 1
+		), comments AS (
+			SELECT date_trunc($2, c.created_at) AS bucket, count(*) AS n
+			FROM comments AS c
+			INNER JOIN posts AS p ON p.id = c.post_id
+			WHERE p.user_id = $1 AND c.user_id <> $1 AND c.created_at >= $3
+			GROUP BY 1
+		), follows AS (
+			SELECT date_trunc($2, f.created_at) AS bucket, count(*) AS n
+			FROM followers AS f
+			WHERE f.user_id = $1 AND f.created_at >= $3
+			GROUP BY 1
+		)
+		SELECT
+			b.bucket,
+			COALESCE(v.n, 0),
+			COALESCE(r.n, 0),
+			COALESCE(c.n, 0),
+			COALESCE(f.n, 0)
+		FROM buckets AS b
+		LEFT JOIN views AS v ON v.bucket = b.bucket
+		LEFT JOIN reactions AS r ON r.bucket = b.bucket
+		LEFT JOIN comments AS c ON c.bucket = b.bucket
+		LEFT JOIN follows AS f ON f.bucket = b.bucket
+		ORDER BY b.bucket
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, series, userID, q.Interval, q.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b AnalyticsBucket
+		if err := rows.Scan(&b.Bucket, &b.Views, &b.Reactions, &b.Comments, &b.NewFollowers); err != nil {
+			return nil, err
+		}
+
+		a.Totals.Views += b.Views
+		a.Totals.Reactions += b.Reactions
+		a.Totals.Comments += b.Comments
+		a.Totals.NewFollowers += b.NewFollowers
+		a.Series = append(a.Series, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	top := `
+		SELECT p.id, p.title, sum(v.views) AS views
+		FROM (` + postViewsSince + `) AS v
+		INNER JOIN posts AS p ON p.id = v.post_id
+		GROUP BY p.id, p.title
+		ORDER BY views DESC, p.id DESC
+		LIMIT 5
+	`
+
+	rows, err = s.db.QueryContext(ctx, top, userID, q.Interval, q.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pv PostViews
+		if err := rows.Scan(&pv.PostID, &pv.Title, &pv.Views); err != nil {
+			return nil, err
+		}
+		a.TopPosts = append(a.TopPosts, pv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRowContext(ctx, `SELECT count(*) FROM followers WHERE user_id = $1`, userID).Scan(&a.Followers)
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
@@ -0,0 +1,205 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path"
+
+	"github.com/lib/pq"
+)
+
+type Attachment struct {
+	ID          int64                        `json:"id"`
+	PostID      int64                        `json:"post_id"`
+	UserID      int64                        `json:"user_id"`
+	Key         string                       `json:"-"`
+	URL         string                       `json:"url"`
+	ContentType string                       `json:"content_type"`
+	Size        int64                        `json:"size"`
+	Width       int                          `json:"width"`
+	Height      int                          `json:"height"`
+	BlurHash    string                       `json:"blurhash"`
+	Variants    map[string]AttachmentVariant `json:"variants"`
+	CreatedAt   string                       `json:"created_at"`
+}
+
+type AttachmentVariant struct {
+	URL    string `json:"url,omitempty"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// VariantKey is where the named variant is stored, next to the original
+func (a *Attachment) VariantKey(name string) string {
+	return path.Join(path.Dir(a.Key), name+".jpg")
+}
+
+type AttachmentStore struct {
+	db *sql.DB
+}
+
+func (s *AttachmentStore) Create(ctx context.Context, a *Attachment) error {
+	query := `
+		INSERT INTO post_attachments (post_id, user_id, key, content_type, size)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		a.PostID,
+		a.UserID,
+		a.Key,
+		a.ContentType,
+		a.Size,
+	).Scan(&a.ID, &a.CreatedAt)
+}
+
+func (s *AttachmentStore) GetByID(ctx context.Context, id int64) (*Attachment, error) {
+	query := `
+		SELECT id, post_id, user_id, key, content_type, size, width, height, blurhash, variants, created_at
+		FROM post_attachments
+		WHERE id = $1
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	a, err := scanAttachment(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+func (s *AttachmentStore) GetByPostIDs(ctx context.Context, postIDs []int64) (map[int64][]Attachment, error) {
+	query := `
+		SELECT id, post_id, user_id, key, content_type, size, width, height, blurhash, variants, created_at
+		FROM post_attachments
+		WHERE post_id = ANY($1)
+		ORDER BY id
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := make(map[int64][]Attachment)
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments[a.PostID] = append(attachments[a.PostID], *a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// UpdateImage stores the dimensions, blurhash and variants computed by the
+// media worker
+func (s *AttachmentStore) UpdateImage(ctx context.Context, a *Attachment) error {
+	query := `
+		UPDATE post_attachments
+		SET width = $1, height = $2, blurhash = $3, variants = $4
+		WHERE id = $5
+	`
+
+	variants, err := json.Marshal(a.Variants)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	res, err := s.db.ExecContext(ctx, query, a.Width, a.Height, a.BlurHash, variants, a.ID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete removes an attachment the media worker could not process
+func (s *AttachmentStore) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM post_attachments WHERE id = $1`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAttachment(row rowScanner) (*Attachment, error) {
+	var (
+		a        Attachment
+		variants []byte
+	)
+
+	err := row.Scan(
+		&a.ID,
+		&a.PostID,
+		&a.UserID,
+		&a.Key,
+		&a.ContentType,
+		&a.Size,
+		&a.Width,
+		&a.Height,
+		&a.BlurHash,
+		&variants,
+		&a.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(variants, &a.Variants); err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// BlockStore keeps blocks and mutes. A block cuts every tie between two
+// users both ways, a mute only hides a user from the feed and notifications
+// of the muter and is never revealed to the muted user.
+type BlockStore struct {
+	db *sql.DB
+}
+
+// blockedBetween is a SQL condition that holds when the users a and b,
+// given as SQL expressions, blocked one another in either direction
+func blockedBetween(a, b string) string {
+	return `EXISTS (
+		SELECT 1 FROM user_blocks AS ub
+		WHERE (ub.blocker_id = ` + a + ` AND ub.blocked_id = ` + b + `)
+			OR (ub.blocker_id = ` + b + ` AND ub.blocked_id = ` + a + `)
+	)`
+}
+
+// mutedBy is a SQL condition that holds when muter muted user
+func mutedBy(muter, user string) string {
+	return `EXISTS (
+		SELECT 1 FROM user_mutes AS um
+		WHERE um.muter_id = ` + muter + ` AND um.muted_id = ` + user + `
+	)`
+}
+
+// Block blocks a user and removes the follows and follow requests between
+// both users
+func (s *BlockStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancle()
+
+		_, err := tx.ExecContext(ctx, `INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2)`, blockerID, blockedID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		query := `
+			DELETE FROM followers
+			WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		`
+
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			return err
+		}
+
+		query = `
+			DELETE FROM follow_requests
+			WHERE (user_id = $1 AND requester_id = $2) OR (user_id = $2 AND requester_id = $1)
+		`
+
+		_, err = tx.ExecContext(ctx, query, blockerID, blockedID)
+		return err
+	})
+}
+
+func (s *BlockStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	return s.delete(ctx, `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`, blockerID, blockedID)
+}
+
+func (s *BlockStore) Mute(ctx context.Context, muterID, mutedID int64) error {
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO user_mutes (muter_id, muted_id) VALUES ($1, $2)`, muterID, mutedID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+	}
+	return err
+}
+
+func (s *BlockStore) Unmute(ctx context.Context, muterID, mutedID int64) error {
+	return s.delete(ctx, `DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2`, muterID, mutedID)
+}
+
+// IsBlocked reports whether either user blocked the other
+func (s *BlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	query := `SELECT ` + blockedBetween("$1::bigint", "$2::bigint")
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	var blocked bool
+	err := s.db.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked)
+	return blocked, err
+}
+
+// GetBlocked lists the users blockerID blocked, most recent first
+func (s *BlockStore) GetBlocked(ctx context.Context, blockerID int64, q PaginatedQuery) ([]User, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_key
+		FROM user_blocks AS b
+		INNER JOIN users AS u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC, u.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	return s.list(ctx, query, blockerID, q)
+}
+
+// GetMuted lists the users muterID muted, most recent first
+func (s *BlockStore) GetMuted(ctx context.Context, muterID int64, q PaginatedQuery) ([]User, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_key
+		FROM user_mutes AS m
+		INNER JOIN users AS u ON u.id = m.muted_id
+		WHERE m.muter_id = $1
+		ORDER BY m.created_at DESC, u.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	return s.list(ctx, query, muterID, q)
+}
+
+func (s *BlockStore) list(ctx context.Context, query string, userID int64, q PaginatedQuery) ([]User, error) {
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.AvatarKey); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+func (s *BlockStore) delete(ctx context.Context, query string, userID, otherID int64) error {
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	res, err := s.db.ExecContext(ctx, query, userID, otherID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
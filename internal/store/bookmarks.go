@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+type BookmarkFolder struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Count     int    `json:"count"`
+	CreatedAt string `json:"created_at"`
+}
+
+type BookmarkStore struct {
+	db *sql.DB
+}
+
+// Save bookmarks a post, or moves an existing bookmark, into folder. An
+// empty folder keeps the bookmark outside of any folder, a nil one leaves an
+// existing bookmark in its folder.
+func (s *BookmarkStore) Save(ctx context.Context, userID, postID int64, folder *string) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		var folderID *int64
+
+		if folder != nil && *folder != "" {
+			id, err := s.upsertFolder(ctx, tx, userID, *folder)
+			if err != nil {
+				return err
+			}
+			folderID = &id
+		}
+
+		query := `
+			INSERT INTO bookmarks (user_id, post_id, folder_id) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, post_id) DO UPDATE
+			SET folder_id = CASE WHEN $4 THEN EXCLUDED.folder_id ELSE bookmarks.folder_id END
+		`
+
+		ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancle()
+
+		_, err := tx.ExecContext(ctx, query, userID, postID, folderID, folder != nil)
+		return err
+	})
+}
+
+func (s *BookmarkStore) Delete(ctx context.Context, userID, postID int64) error {
+	query := `
+		DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetByUserID lists the bookmarked posts of a user, most recently saved
+// first, optionally restricted to one folder. Posts the user can no longer see
+// are left out.
+func (s *BookmarkStore) GetByUserID(ctx context.Context, userID int64, folder string, q PaginatedQuery) ([]PostWithMetadata, error) {
+	query := `
+		SELECT ` + postWithMetadataColumns + `
+		FROM bookmarks AS b
+		INNER JOIN posts AS p ON p.id = b.post_id
+		INNER JOIN users AS u ON p.user_id = u.id
+		LEFT JOIN bookmark_folders AS bf ON bf.id = b.folder_id
+		WHERE b.user_id = $1 AND ($2::varchar = '' OR bf.name = $2)
+			AND NOT ` + blockedBetween("$1", "p.user_id") + `
+			AND ` + visibleTo("u", "$1") + `
+		ORDER BY b.created_at DESC, p.id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, folder, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithMetadata{}
+	for rows.Next() {
+		p, err := scanPostWithMetadata(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookmarked := true
+		p.Bookmarked = &bookmarked
+		posts = append(posts, *p)
+	}
+
+	return posts, rows.Err()
+}
+
+// GetBookmarked reports which of postIDs the user bookmarked
+func (s *BookmarkStore) GetBookmarked(ctx context.Context, userID int64, postIDs []int64) (map[int64]bool, error) {
+	query := `
+		SELECT post_id FROM bookmarks WHERE user_id = $1 AND post_id = ANY($2)
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarked := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		bookmarked[id] = true
+	}
+
+	return bookmarked, rows.Err()
+}
+
+func (s *BookmarkStore) GetFolders(ctx context.Context, userID int64) ([]BookmarkFolder, error) {
+	query := `
+		SELECT bf.id, bf.name, count(b.post_id), bf.created_at
+		FROM bookmark_folders AS bf
+		LEFT JOIN bookmarks AS b ON b.folder_id = bf.id
+		WHERE bf.user_id = $1
+		GROUP BY bf.id
+		ORDER BY bf.name
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := []BookmarkFolder{}
+	for rows.Next() {
+		var f BookmarkFolder
+		if err := rows.Scan(&f.ID, &f.Name, &f.Count, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+
+	return folders, rows.Err()
+}
+
+func (s *BookmarkStore) upsertFolder(ctx context.Context, tx *sql.Tx, userID int64, name string) (int64, error) {
+	query := `
+		INSERT INTO bookmark_folders (user_id, name) VALUES ($1, $2)
+		ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	var id int64
+	err := tx.QueryRowContext(ctx, query, userID, name).Scan(&id)
+	return id, err
+}
@@ -2,13 +2,15 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/yunsuk-jeung/social/internal/store"
 )
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:     &MockUserStore{},
+		Timelines: &MockTimelineStore{},
 	}
 }
 
@@ -17,3 +19,25 @@ type MockUserStore struct{}
 func (s *MockUserStore) Get(ctx context.Context, userID int64) (*store.User, error) { return nil, nil }
 
 func (s *MockUserStore) Set(ctx context.Context, user *store.User) error { return nil }
+
+func (s *MockUserStore) Delete(ctx context.Context, userID int64) error { return nil }
+
+type MockTimelineStore struct{}
+
+func (s *MockTimelineStore) Get(ctx context.Context, userID int64, cursor *store.FeedCursor, limit int) ([]store.FeedEntry, bool, error) {
+	return nil, false, nil
+}
+
+func (s *MockTimelineStore) Fill(ctx context.Context, userID int64, entries []store.FeedEntry, ttl time.Duration) error {
+	return nil
+}
+
+func (s *MockTimelineStore) Add(ctx context.Context, userIDs []int64, entry store.FeedEntry, length int) error {
+	return nil
+}
+
+func (s *MockTimelineStore) Remove(ctx context.Context, userIDs []int64, postID int64) error {
+	return nil
+}
+
+func (s *MockTimelineStore) Delete(ctx context.Context, userIDs ...int64) error { return nil }
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/yunsuk-jeung/social/internal/store"
@@ -11,11 +12,20 @@ type Storage struct {
 	Users interface {
 		Get(context.Context, int64) (*store.User, error)
 		Set(context.Context, *store.User) error
+		Delete(context.Context, int64) error
+	}
+	Timelines interface {
+		Get(ctx context.Context, userID int64, cursor *store.FeedCursor, limit int) ([]store.FeedEntry, bool, error)
+		Fill(ctx context.Context, userID int64, entries []store.FeedEntry, ttl time.Duration) error
+		Add(ctx context.Context, userIDs []int64, entry store.FeedEntry, length int) error
+		Remove(ctx context.Context, userIDs []int64, postID int64) error
+		Delete(ctx context.Context, userIDs ...int64) error
 	}
 }
 
 func NewRedisStorage(rdb *redis.Client) Storage {
 	return Storage{
-		Users: &UserStore{rdb: rdb},
+		Users:     &UserStore{rdb: rdb},
+		Timelines: &TimelineStore{rdb: rdb},
 	}
 }
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+// TimelineStore keeps the home timelines of users as sorted sets of post IDs
+// scored by the unix second they were posted or reposted at. Members are
+// zero padded so that entries posted in the same second sort by ID, as they
+// do in the database.
+type TimelineStore struct {
+	rdb *redis.Client
+}
+
+// keys per fan out script call
+const timelineBatchSize = 500
+
+// timelineAdd adds an entry to the timelines that exist, a post seen again
+// moves up to its most recent appearance, and trims them to their length
+var timelineAdd = redis.NewScript(`
+	for _, key in ipairs(KEYS) do
+		if redis.call('EXISTS', key) == 1 then
+			redis.call('ZADD', key, 'GT', ARGV[1], ARGV[2])
+			redis.call('ZREMRANGEBYRANK', key, 0, -(tonumber(ARGV[3]) + 1))
+		end
+	end
+	return 0
+`)
+
+func timelineKey(userID int64) string {
+	return fmt.Sprintf("timeline-%v", userID)
+}
+
+func timelineMember(postID int64) string {
+	return fmt.Sprintf("%019d", postID)
+}
+
+// Get reads the entries of the timeline of userID after cursor, newest
+// first. A timeline that does not exist is cold and has to be filled.
+func (s *TimelineStore) Get(ctx context.Context, userID int64, cursor *store.FeedCursor, limit int) ([]store.FeedEntry, bool, error) {
+	key := timelineKey(userID)
+
+	upper := "+inf"
+	if cursor != nil {
+		upper = strconv.FormatInt(cursor.SortAt.Unix(), 10)
+	}
+
+	entries := []store.FeedEntry{}
+	offset := int64(0)
+
+	for len(entries) < limit {
+		members, err := s.rdb.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+			Max:    upper,
+			Min:    "-inf",
+			Offset: offset,
+			Count:  int64(limit),
+		}).Result()
+		if err != nil {
+			return nil, false, err
+		}
+
+		if len(members) == 0 {
+			break
+		}
+		offset += int64(len(members))
+
+		for _, m := range members {
+			postID, err := strconv.ParseInt(m.Member.(string), 10, 64)
+			if err != nil {
+				return nil, false, err
+			}
+
+			e := store.FeedEntry{PostID: postID, SortAt: time.Unix(int64(m.Score), 0).UTC()}
+
+			if !afterCursor(e, cursor) {
+				continue
+			}
+
+			entries = append(entries, e)
+			if len(entries) == limit {
+				break
+			}
+		}
+
+		if len(members) < limit {
+			break
+		}
+	}
+
+	if len(entries) > 0 {
+		return entries, true, nil
+	}
+
+	exists, err := s.rdb.Exists(ctx, key).Result()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return entries, exists == 1, nil
+}
+
+// afterCursor tells whether an entry read from the cursor second on belongs
+// to the next page, entries of that second up to the cursor were on the
+// previous one
+func afterCursor(e store.FeedEntry, cursor *store.FeedCursor) bool {
+	if cursor == nil {
+		return true
+	}
+
+	at := cursor.SortAt.Truncate(time.Second)
+	if e.SortAt.Before(at) {
+		return true
+	}
+
+	return e.SortAt.Equal(at) && e.PostID < cursor.PostID
+}
+
+// Fill replaces the timeline of userID, it expires after ttl so that changes
+// that were not fanned out are eventually picked up
+func (s *TimelineStore) Fill(ctx context.Context, userID int64, entries []store.FeedEntry, ttl time.Duration) error {
+	key := timelineKey(userID)
+
+	members := make([]*redis.Z, len(entries))
+	for i, e := range entries {
+		members[i] = &redis.Z{Score: float64(e.SortAt.Unix()), Member: timelineMember(e.PostID)}
+	}
+
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		if len(members) > 0 {
+			pipe.ZAdd(ctx, key, members...)
+			pipe.Expire(ctx, key, ttl)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// Add fans an entry out to the timelines of userIDs that exist, each keeps
+// its length most recent entries
+func (s *TimelineStore) Add(ctx context.Context, userIDs []int64, entry store.FeedEntry, length int) error {
+	for start := 0; start < len(userIDs); start += timelineBatchSize {
+		end := min(start+timelineBatchSize, len(userIDs))
+
+		keys := make([]string, 0, end-start)
+		for _, id := range userIDs[start:end] {
+			keys = append(keys, timelineKey(id))
+		}
+
+		err := timelineAdd.Run(ctx, s.rdb, keys, entry.SortAt.Unix(), timelineMember(entry.PostID), length).Err()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove takes a post out of the timelines of userIDs
+func (s *TimelineStore) Remove(ctx context.Context, userIDs []int64, postID int64) error {
+	member := timelineMember(postID)
+
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range userIDs {
+			pipe.ZRem(ctx, timelineKey(id), member)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// Delete drops the timelines of userIDs, they are filled again on their
+// next read
+func (s *TimelineStore) Delete(ctx context.Context, userIDs ...int64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = timelineKey(id)
+	}
+
+	return s.rdb.Del(ctx, keys...).Err()
+}
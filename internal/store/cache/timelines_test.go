@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yunsuk-jeung/social/internal/store"
+)
+
+func TestAfterCursor(t *testing.T) {
+	at := time.Unix(1700000000, 0).UTC()
+	// timeline scores are whole seconds, cursors are compared at that precision
+	cursor := &store.FeedCursor{SortAt: at.Add(time.Millisecond * 300), PostID: 20}
+
+	tests := []struct {
+		name   string
+		entry  store.FeedEntry
+		cursor *store.FeedCursor
+		want   bool
+	}{
+		{"first page", store.FeedEntry{PostID: 20, SortAt: at}, nil, true},
+		{"earlier second", store.FeedEntry{PostID: 99, SortAt: at.Add(-time.Second)}, cursor, true},
+		{"same second, lower id", store.FeedEntry{PostID: 19, SortAt: at}, cursor, true},
+		{"the cursor entry", store.FeedEntry{PostID: 20, SortAt: at}, cursor, false},
+		{"same second, higher id", store.FeedEntry{PostID: 21, SortAt: at}, cursor, false},
+	}
+
+	for _, tt := range tests {
+		if got := afterCursor(tt.entry, tt.cursor); got != tt.want {
+			t.Errorf("%s: afterCursor() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -6,19 +6,20 @@ import (
 )
 
 type Comment struct {
-	ID        int64  `json:"id"`
-	PostID    int64  `json:"post_id"`
-	UserID    int64  `json:"user_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	User      User   `json:"user"`
+	ID        int64     `json:"id"`
+	PostID    int64     `json:"post_id"`
+	UserID    int64     `json:"user_id"`
+	Content   string    `json:"content"`
+	CreatedAt string    `json:"created_at"`
+	User      User      `json:"user"`
+	Mentions  []Mention `json:"mentions,omitempty"`
 }
 
 type CommentStore struct {
 	db *sql.DB
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+func (s *CommentStore) GetByPostID(ctx context.Context, postID, viewerID int64) ([]Comment, error) {
 	query := `
 		SELECT
 				c.id,
@@ -27,16 +28,18 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment
 				c.content,
 				c.created_at,
 				users.username,
-				users.id
+				users.id,
+				users.display_name,
+				users.avatar_key
 		FROM comments AS c
 		INNER JOIN users ON c.user_id = users.id
-		WHERE c.post_id = $1
+		WHERE c.post_id = $1 AND NOT ` + blockedBetween("$2", "c.user_id") + `
 		ORDER BY c.created_at DESC;
 	`
 	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancle()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -54,6 +57,8 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment
 			&c.CreatedAt,
 			&c.User.Username,
 			&c.User.ID,
+			&c.User.DisplayName,
+			&c.User.AvatarKey,
 		)
 		if err != nil {
 			return nil, nil
@@ -34,17 +34,345 @@ func (s *FollowerStore) Follow(ctx context.Context, followerId, userID int64) er
 	return err
 }
 
+// Unfollow removes a follow, or withdraws the request to follow a private
+// account
 func (s *FollowerStore) Unfollow(ctx context.Context, followerId, userID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancle()
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM followers WHERE user_id = $1 AND follower_id = $2`, userID, followerId)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM follow_requests WHERE user_id = $1 AND requester_id = $2`, userID, followerId)
+		return err
+	})
+}
+
+// FollowRequest is a pending request to follow a private account
+type FollowRequest struct {
+	User
+	RequestedAt string `json:"requested_at"`
+}
+
+// RequestFollow asks to follow the private account userID, it conflicts
+// when a request is pending or requesterID already follows
+func (s *FollowerStore) RequestFollow(ctx context.Context, requesterID, userID int64) error {
 	query := `
-	DELETE FROM followers
-	WHERE user_id = $1 AND follower_id = $2
+		INSERT INTO follow_requests (user_id, requester_id)
+		SELECT $1, $2
+		WHERE NOT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)
 	`
 
 	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancle()
 
-	_, err := s.db.ExecContext(ctx, query, userID, followerId)
-	return err
+	res, err := s.db.ExecContext(ctx, query, userID, requesterID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+// GetRequests lists the pending requests to follow userID, oldest first
+func (s *FollowerStore) GetRequests(ctx context.Context, userID int64, q PaginatedQuery) ([]FollowRequest, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.bio, u.avatar_key, fr.created_at
+		FROM follow_requests AS fr
+		INNER JOIN users AS u ON u.id = fr.requester_id
+		WHERE fr.user_id = $1 AND u.is_active
+			AND NOT ` + blockedBetween("fr.user_id", "fr.requester_id") + `
+		ORDER BY fr.created_at, u.id
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []FollowRequest{}
+	for rows.Next() {
+		var fr FollowRequest
+
+		err := rows.Scan(&fr.ID, &fr.Username, &fr.DisplayName, &fr.Bio, &fr.AvatarKey, &fr.RequestedAt)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, fr)
+	}
+
+	return requests, rows.Err()
+}
+
+// ApproveRequest turns the pending request of requesterID into a follow
+func (s *FollowerStore) ApproveRequest(ctx context.Context, userID, requesterID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancle()
+
+		query := `
+			DELETE FROM follow_requests
+			WHERE user_id = $1 AND requester_id = $2 AND NOT ` + blockedBetween("$1", "$2") + `
+		`
+
+		res, err := tx.ExecContext(ctx, query, userID, requesterID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO followers (user_id, follower_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, requesterID)
+		return err
+	})
+}
+
+// RejectRequest drops the pending request of requesterID, they are not told
+func (s *FollowerStore) RejectRequest(ctx context.Context, userID, requesterID int64) error {
+	query := `DELETE FROM follow_requests WHERE user_id = $1 AND requester_id = $2`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	res, err := s.db.ExecContext(ctx, query, userID, requesterID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// visibleTo is a SQL condition that holds when the posts of author, an alias
+// of users, can be seen by viewer: the account is public, or viewer is the
+// author or one of their approved followers
+func visibleTo(author, viewer string) string {
+	return `(NOT ` + author + `.is_private OR ` + author + `.id = ` + viewer + ` OR EXISTS (
+		SELECT 1 FROM followers AS vf
+		WHERE vf.user_id = ` + author + `.id AND vf.follower_id = ` + viewer + `
+	))`
+}
+
+// Relationship is how a user relates to the one viewing them. Muting is only
+// ever shown to the viewer who muted, a user never learns they were muted.
+// Requested is a pending request of the viewer to follow a private account.
+type Relationship struct {
+	FollowsYou bool `json:"follows_you"`
+	YouFollow  bool `json:"you_follow"`
+	Requested  bool `json:"requested"`
+	Muting     bool `json:"muting"`
+}
+
+type FollowCounts struct {
+	Followers int64 `json:"followers_count"`
+	Following int64 `json:"following_count"`
+}
+
+// FollowListUser is an entry of a followers or following list
+type FollowListUser struct {
+	User
+	Relationship
+	FollowedAt string `json:"followed_at"`
+}
+
+// GetFollowers lists the users following userID, most recent first, with
+// their relationship to viewerID. Users viewerID is blocked with are left out.
+func (s *FollowerStore) GetFollowers(ctx context.Context, userID, viewerID int64, q PaginatedQuery) ([]FollowListUser, error) {
+	query := `
+		SELECT ` + followListColumns + `
+		FROM followers AS f
+		INNER JOIN users AS u ON u.id = f.follower_id
+		WHERE f.user_id = $1 AND u.is_active
+			AND NOT ` + blockedBetween("$2", "u.id") + `
+		ORDER BY f.created_at DESC, u.id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	return s.list(ctx, query, userID, viewerID, q)
+}
+
+// GetFollowing lists the users userID follows, most recent first, with
+// their relationship to viewerID. Users viewerID is blocked with are left out.
+func (s *FollowerStore) GetFollowing(ctx context.Context, userID, viewerID int64, q PaginatedQuery) ([]FollowListUser, error) {
+	query := `
+		SELECT ` + followListColumns + `
+		FROM followers AS f
+		INNER JOIN users AS u ON u.id = f.user_id
+		WHERE f.follower_id = $1 AND u.is_active
+			AND NOT ` + blockedBetween("$2", "u.id") + `
+		ORDER BY f.created_at DESC, u.id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	return s.list(ctx, query, userID, viewerID, q)
+}
+
+func (s *FollowerStore) GetCounts(ctx context.Context, userID int64) (*FollowCounts, error) {
+	query := `
+		SELECT
+			(SELECT count(*) FROM followers WHERE user_id = $1),
+			(SELECT count(*) FROM followers WHERE follower_id = $1)
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	var c FollowCounts
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&c.Followers, &c.Following); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// HasFollowers reports whether userID has at least count followers, without
+// counting past it
+func (s *FollowerStore) HasFollowers(ctx context.Context, userID int64, count int) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 OFFSET $2 - 1)
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	var has bool
+	err := s.db.QueryRowContext(ctx, query, userID, max(count, 1)).Scan(&has)
+	return has, err
+}
+
+// GetFollowerIDs pages through the IDs of the followers of userID in
+// ascending order, starting after afterID
+func (s *FollowerStore) GetFollowerIDs(ctx context.Context, userID, afterID int64, limit int) ([]int64, error) {
+	query := `
+		SELECT follower_id FROM followers
+		WHERE user_id = $1 AND follower_id > $2
+		ORDER BY follower_id
+		LIMIT $3
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetRelationship returns how userID relates to viewerID
+func (s *FollowerStore) GetRelationship(ctx context.Context, viewerID, userID int64) (*Relationship, error) {
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2),
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $2 AND follower_id = $1),
+			EXISTS (SELECT 1 FROM follow_requests WHERE user_id = $2 AND requester_id = $1),
+			EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = $1 AND muted_id = $2)
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	var r Relationship
+	if err := s.db.QueryRowContext(ctx, query, viewerID, userID).Scan(&r.FollowsYou, &r.YouFollow, &r.Requested, &r.Muting); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// columns read by list, u is the listed user and $2 the viewer
+const followListColumns = `
+			u.id,
+			u.username,
+			u.display_name,
+			u.bio,
+			u.avatar_key,
+			f.created_at,
+			EXISTS (SELECT 1 FROM followers AS x WHERE x.user_id = $2 AND x.follower_id = u.id),
+			EXISTS (SELECT 1 FROM followers AS x WHERE x.user_id = u.id AND x.follower_id = $2),
+			EXISTS (SELECT 1 FROM follow_requests AS x WHERE x.user_id = u.id AND x.requester_id = $2),
+			EXISTS (SELECT 1 FROM user_mutes AS x WHERE x.muter_id = $2 AND x.muted_id = u.id)`
+
+func (s *FollowerStore) list(ctx context.Context, query string, userID, viewerID int64, q PaginatedQuery) ([]FollowListUser, error) {
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, viewerID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []FollowListUser{}
+	for rows.Next() {
+		var u FollowListUser
+
+		err := rows.Scan(
+			&u.ID,
+			&u.Username,
+			&u.DisplayName,
+			&u.Bio,
+			&u.AvatarKey,
+			&u.FollowedAt,
+			&u.FollowsYou,
+			&u.YouFollow,
+			&u.Requested,
+			&u.Muting,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
 }
 
 // func (s *FollowerStore) Follow(ctx context.Context, followerID, userID int64) error {
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ImageURL    string `json:"image_url"`
+	SiteName    string `json:"site_name"`
+	Failed      bool   `json:"-"`
+	FetchedAt   string `json:"fetched_at"`
+}
+
+type LinkStore struct {
+	db *sql.DB
+}
+
+// SetForPost replaces the links of a post, urls are kept in order
+func (s *LinkStore) SetForPost(ctx context.Context, postID int64, urls []string) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancle()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM post_links WHERE post_id = $1`, postID); err != nil {
+			return err
+		}
+
+		if len(urls) == 0 {
+			return nil
+		}
+
+		query := `
+			INSERT INTO post_links (post_id, url, position)
+			SELECT $1, u.url, u.position
+			FROM unnest($2::text[]) WITH ORDINALITY AS u(url, position)
+		`
+
+		_, err := tx.ExecContext(ctx, query, postID, pq.Array(urls))
+		return err
+	})
+}
+
+// GetPreview returns the cached preview of url, ErrNotFound when it was
+// never fetched or the fetch is older than maxAge
+func (s *LinkStore) GetPreview(ctx context.Context, url string, maxAge time.Duration) (*LinkPreview, error) {
+	query := `
+		SELECT url, title, description, image_url, site_name, failed, fetched_at
+		FROM link_previews
+		WHERE url = $1 AND fetched_at > NOW() - $2 * interval '1 second'
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	var p LinkPreview
+	err := s.db.QueryRowContext(ctx, query, url, maxAge.Seconds()).Scan(
+		&p.URL,
+		&p.Title,
+		&p.Description,
+		&p.ImageURL,
+		&p.SiteName,
+		&p.Failed,
+		&p.FetchedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+// SavePreview caches the preview of a URL, replacing an older fetch
+func (s *LinkStore) SavePreview(ctx context.Context, p *LinkPreview) error {
+	query := `
+		INSERT INTO link_previews (url, title, description, image_url, site_name, failed, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (url) DO UPDATE
+		SET title = EXCLUDED.title,
+			description = EXCLUDED.description,
+			image_url = EXCLUDED.image_url,
+			site_name = EXCLUDED.site_name,
+			failed = EXCLUDED.failed,
+			fetched_at = EXCLUDED.fetched_at
+		RETURNING fetched_at
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		p.URL,
+		p.Title,
+		p.Description,
+		p.ImageURL,
+		p.SiteName,
+		p.Failed,
+	).Scan(&p.FetchedAt)
+}
+
+// GetPreviewsByPostIDs returns the fetched previews of the links of every
+// post, links that failed or are still being fetched are left out
+func (s *LinkStore) GetPreviewsByPostIDs(ctx context.Context, postIDs []int64) (map[int64][]LinkPreview, error) {
+	query := `
+		SELECT pl.post_id, lp.url, lp.title, lp.description, lp.image_url, lp.site_name, lp.fetched_at
+		FROM post_links AS pl
+		INNER JOIN link_previews AS lp ON lp.url = pl.url
+		WHERE pl.post_id = ANY($1) AND NOT lp.failed
+		ORDER BY pl.post_id, pl.position
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	previews := make(map[int64][]LinkPreview)
+	for rows.Next() {
+		var (
+			postID int64
+			p      LinkPreview
+		)
+
+		err := rows.Scan(&postID, &p.URL, &p.Title, &p.Description, &p.ImageURL, &p.SiteName, &p.FetchedAt)
+		if err != nil {
+			return nil, err
+		}
+		previews[postID] = append(previews[postID], p)
+	}
+
+	return previews, rows.Err()
+}
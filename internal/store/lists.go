@@ -0,0 +1,262 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var ErrListFull = errors.New("list members limit reached")
+
+// List is a group of accounts curated by its owner, a private list is only
+// seen by its owner
+type List struct {
+	ID          int64  `json:"id"`
+	UserID      int64  `json:"user_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	IsPrivate   bool   `json:"is_private"`
+	MemberCount int    `json:"member_count"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+type ListStore struct {
+	db *sql.DB
+}
+
+func (s *ListStore) Create(ctx context.Context, list *List) error {
+	query := `
+		INSERT INTO lists (user_id, name, description, is_private)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		list.UserID,
+		list.Name,
+		list.Description,
+		list.IsPrivate,
+	).Scan(
+		&list.ID,
+		&list.CreatedAt,
+		&list.UpdatedAt,
+	)
+}
+
+// columns read by scanList, l is the list
+const listColumns = `
+	l.id, l.user_id, l.name, l.description, l.is_private, l.created_at, l.updated_at,
+	(SELECT count(*) FROM list_members AS m WHERE m.list_id = l.id)`
+
+func scanList(row rowScanner) (*List, error) {
+	var l List
+
+	err := row.Scan(&l.ID, &l.UserID, &l.Name, &l.Description, &l.IsPrivate, &l.CreatedAt, &l.UpdatedAt, &l.MemberCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+func (s *ListStore) GetByID(ctx context.Context, id int64) (*List, error) {
+	query := `SELECT ` + listColumns + ` FROM lists AS l WHERE l.id = $1`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	list, err := scanList(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// GetByUserID lists the lists owned by a user, most recent first, private
+// ones only when includePrivate is set
+func (s *ListStore) GetByUserID(ctx context.Context, userID int64, includePrivate bool, q PaginatedQuery) ([]List, error) {
+	query := `
+		SELECT ` + listColumns + `
+		FROM lists AS l
+		WHERE l.user_id = $1 AND ($2 OR NOT l.is_private)
+		ORDER BY l.created_at DESC, l.id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, includePrivate, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []List{}
+	for rows.Next() {
+		l, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, *l)
+	}
+
+	return lists, rows.Err()
+}
+
+func (s *ListStore) Update(ctx context.Context, list *List) error {
+	query := `
+		UPDATE lists
+		SET name = $1, description = $2, is_private = $3, updated_at = NOW()
+		WHERE id = $4
+		RETURNING updated_at
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	err := s.db.QueryRowContext(ctx, query, list.Name, list.Description, list.IsPrivate, list.ID).Scan(&list.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *ListStore) Delete(ctx context.Context, id int64) error {
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM lists WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// AddMember adds a user to a list unless it has limit members already
+func (s *ListStore) AddMember(ctx context.Context, listID, userID int64, limit int) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancle()
+
+		// concurrent additions to the same list wait here, so that they
+		// count the members added by one another
+		var locked int
+		err := tx.QueryRowContext(ctx, `SELECT 1 FROM lists WHERE id = $1 FOR UPDATE`, listID).Scan(&locked)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		query := `
+			INSERT INTO list_members (list_id, user_id)
+			SELECT $1, $2
+			WHERE (SELECT count(*) FROM list_members WHERE list_id = $1) < $3
+		`
+
+		res, err := tx.ExecContext(ctx, query, listID, userID, limit)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrListFull
+		}
+
+		return nil
+	})
+}
+
+func (s *ListStore) RemoveMember(ctx context.Context, listID, userID int64) error {
+	query := `
+		DELETE FROM list_members WHERE list_id = $1 AND user_id = $2
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	res, err := s.db.ExecContext(ctx, query, listID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetMembers lists the members of a list, most recently added first. Users
+// viewerID is blocked with are left out.
+func (s *ListStore) GetMembers(ctx context.Context, listID, viewerID int64, q PaginatedQuery) ([]User, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_key
+		FROM list_members AS m
+		INNER JOIN users AS u ON u.id = m.user_id
+		WHERE m.list_id = $1 AND u.is_active
+			AND NOT ` + blockedBetween("$2", "u.id") + `
+		ORDER BY m.created_at DESC, u.id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, listID, viewerID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.AvatarKey); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type Mention struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+}
+
+type MentionStore struct {
+	db *sql.DB
+}
+
+// SetForPost replaces the mentions in the body of a post. It returns every
+// mentioned user and the IDs of the users that were not mentioned before.
+func (s *MentionStore) SetForPost(ctx context.Context, post *Post, usernames []string) ([]Mention, []int64, error) {
+	return s.set(ctx, post.UserID, post.ID, nil, usernames)
+}
+
+// SetForComment is SetForPost for the content of a comment
+func (s *MentionStore) SetForComment(ctx context.Context, comment *Comment, usernames []string) ([]Mention, []int64, error) {
+	return s.set(ctx, comment.UserID, comment.PostID, &comment.ID, usernames)
+}
+
+func (s *MentionStore) GetByPostID(ctx context.Context, postID int64) ([]Mention, error) {
+	query := `
+		SELECT u.id, u.username
+		FROM mentions AS m
+		INNER JOIN users AS u ON u.id = m.user_id
+		WHERE m.post_id = $1 AND m.comment_id IS NULL
+		ORDER BY u.username
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []Mention{}
+	for rows.Next() {
+		var m Mention
+		if err := rows.Scan(&m.UserID, &m.Username); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, m)
+	}
+
+	return mentions, rows.Err()
+}
+
+// GetForComments loads the mentions of every comment of a post, by comment ID
+func (s *MentionStore) GetForComments(ctx context.Context, postID int64) (map[int64][]Mention, error) {
+	query := `
+		SELECT m.comment_id, u.id, u.username
+		FROM mentions AS m
+		INNER JOIN users AS u ON u.id = m.user_id
+		WHERE m.post_id = $1 AND m.comment_id IS NOT NULL
+		ORDER BY m.comment_id, u.username
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := make(map[int64][]Mention)
+	for rows.Next() {
+		var (
+			commentID int64
+			m         Mention
+		)
+		if err := rows.Scan(&commentID, &m.UserID, &m.Username); err != nil {
+			return nil, err
+		}
+		mentions[commentID] = append(mentions[commentID], m)
+	}
+
+	return mentions, rows.Err()
+}
+
+func (s *MentionStore) set(ctx context.Context, authorID, postID int64, commentID *int64, usernames []string) ([]Mention, []int64, error) {
+	var (
+		mentions []Mention
+		added    []int64
+	)
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		var err error
+
+		mentions, err = s.resolve(ctx, tx, authorID, usernames)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, len(mentions))
+		for i, m := range mentions {
+			ids[i] = m.UserID
+		}
+
+		if err := s.deleteExcept(ctx, tx, postID, commentID, ids); err != nil {
+			return err
+		}
+
+		added, err = s.insert(ctx, tx, authorID, postID, commentID, ids)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return mentions, added, nil
+}
+
+// resolve looks up the mentioned users, users the author is blocked with
+// cannot be mentioned
+func (s *MentionStore) resolve(ctx context.Context, tx *sql.Tx, authorID int64, usernames []string) ([]Mention, error) {
+	mentions := []Mention{}
+	if len(usernames) == 0 {
+		return mentions, nil
+	}
+
+	// a username released recently still mentions its previous owner
+	query := `
+		SELECT u.id, u.username
+		FROM (
+			SELECT id, username
+			FROM users
+			WHERE lower(username) = ANY($1) AND is_active = true
+			UNION
+			(` + previousUsernames + `)
+		) AS u
+		WHERE NOT ` + blockedBetween("$2", "u.id") + `
+		ORDER BY u.username
+	`
+
+	lower := make([]string, len(usernames))
+	for i, u := range usernames {
+		lower[i] = strings.ToLower(u)
+	}
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(lower), authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m Mention
+		if err := rows.Scan(&m.UserID, &m.Username); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, m)
+	}
+
+	return mentions, rows.Err()
+}
+
+func (s *MentionStore) deleteExcept(ctx context.Context, tx *sql.Tx, postID int64, commentID *int64, userIDs []int64) error {
+	query := `
+		DELETE FROM mentions
+		WHERE post_id = $1
+			AND comment_id IS NOT DISTINCT FROM $2::bigint
+			AND NOT (user_id = ANY($3))
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	_, err := tx.ExecContext(ctx, query, postID, commentID, pq.Array(userIDs))
+	return err
+}
+
+func (s *MentionStore) insert(ctx context.Context, tx *sql.Tx, authorID, postID int64, commentID *int64, userIDs []int64) ([]int64, error) {
+	query := `
+		INSERT INTO mentions (user_id, author_id, post_id, comment_id)
+		SELECT unnest($1::bigint[]), $2, $3, $4
+		ON CONFLICT DO NOTHING
+		RETURNING user_id
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(userIDs), authorID, postID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var added []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		added = append(added, id)
+	}
+
+	return added, rows.Err()
+}
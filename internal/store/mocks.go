@@ -8,7 +8,10 @@ import (
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:     &MockUserStore{},
+		Pins:      &MockPinStore{},
+		Followers: &MockFollowerStore{},
+		Blocks:    &MockBlockStore{},
 	}
 }
 
@@ -46,4 +49,100 @@ func (s *MockUserStore) Activate(ctx context.Context, token string) error { retu
 
 func (s *MockUserStore) Delete(ctx context.Context, userID int64) error { return nil }
 
+func (s *MockUserStore) UpdateProfile(ctx context.Context, user *User) error { return nil }
+
+func (s *MockUserStore) SetAvatar(ctx context.Context, userID int64, key string) (string, error) {
+	return "", nil
+}
+
+func (s *MockUserStore) Search(ctx context.Context, q UserSearchQuery) ([]UserMatch, error) {
+	return []UserMatch{}, nil
+}
+
+func (s *MockUserStore) LookupUsername(ctx context.Context, username string) (int64, string, error) {
+	return 1, username, nil
+}
+
+func (s *MockUserStore) ChangeUsername(ctx context.Context, userID int64, username string, policy UsernamePolicy) error {
+	return nil
+}
+
 // func (s *MockUserStore) delete(ctx context.Context, tx *sql.Tx, userID int64) error { return nil }
+
+type MockPinStore struct{}
+
+func (s *MockPinStore) Pin(ctx context.Context, userID, postID int64, limit int) error { return nil }
+
+func (s *MockPinStore) Unpin(ctx context.Context, userID, postID int64) error { return nil }
+
+func (s *MockPinStore) GetByUserID(ctx context.Context, userID int64) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+type MockFollowerStore struct{}
+
+func (s *MockFollowerStore) Follow(ctx context.Context, followerID, userID int64) error { return nil }
+
+func (s *MockFollowerStore) Unfollow(ctx context.Context, followerID, userID int64) error { return nil }
+
+func (s *MockFollowerStore) GetFollowers(ctx context.Context, userID, viewerID int64, q PaginatedQuery) ([]FollowListUser, error) {
+	return []FollowListUser{}, nil
+}
+
+func (s *MockFollowerStore) GetFollowing(ctx context.Context, userID, viewerID int64, q PaginatedQuery) ([]FollowListUser, error) {
+	return []FollowListUser{}, nil
+}
+
+func (s *MockFollowerStore) GetCounts(ctx context.Context, userID int64) (*FollowCounts, error) {
+	return &FollowCounts{}, nil
+}
+
+func (s *MockFollowerStore) HasFollowers(ctx context.Context, userID int64, count int) (bool, error) {
+	return false, nil
+}
+
+func (s *MockFollowerStore) GetFollowerIDs(ctx context.Context, userID, afterID int64, limit int) ([]int64, error) {
+	return nil, nil
+}
+
+func (s *MockFollowerStore) GetRelationship(ctx context.Context, viewerID, userID int64) (*Relationship, error) {
+	return &Relationship{}, nil
+}
+
+func (s *MockFollowerStore) RequestFollow(ctx context.Context, requesterID, userID int64) error {
+	return nil
+}
+
+func (s *MockFollowerStore) GetRequests(ctx context.Context, userID int64, q PaginatedQuery) ([]FollowRequest, error) {
+	return []FollowRequest{}, nil
+}
+
+func (s *MockFollowerStore) ApproveRequest(ctx context.Context, userID, requesterID int64) error {
+	return nil
+}
+
+func (s *MockFollowerStore) RejectRequest(ctx context.Context, userID, requesterID int64) error {
+	return nil
+}
+
+type MockBlockStore struct{}
+
+func (s *MockBlockStore) Block(ctx context.Context, blockerID, blockedID int64) error { return nil }
+
+func (s *MockBlockStore) Unblock(ctx context.Context, blockerID, blockedID int64) error { return nil }
+
+func (s *MockBlockStore) Mute(ctx context.Context, muterID, mutedID int64) error { return nil }
+
+func (s *MockBlockStore) Unmute(ctx context.Context, muterID, mutedID int64) error { return nil }
+
+func (s *MockBlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	return false, nil
+}
+
+func (s *MockBlockStore) GetBlocked(ctx context.Context, blockerID int64, q PaginatedQuery) ([]User, error) {
+	return []User{}, nil
+}
+
+func (s *MockBlockStore) GetMuted(ctx context.Context, muterID int64, q PaginatedQuery) ([]User, error) {
+	return []User{}, nil
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+const (
+	NotificationMention        = "mention"
+	NotificationFollowRequest  = "follow_request"
+	NotificationFollowAccepted = "follow_accepted"
+)
+
+type Notification struct {
+	ID        int64   `json:"id"`
+	UserID    int64   `json:"user_id"`
+	ActorID   int64   `json:"actor_id"`
+	Type      string  `json:"type"`
+	PostID    *int64  `json:"post_id,omitempty"`
+	CommentID *int64  `json:"comment_id,omitempty"`
+	ReadAt    *string `json:"read_at"`
+	CreatedAt string  `json:"created_at"`
+	Actor     User    `json:"actor"`
+}
+
+type NotificationStore struct {
+	db *sql.DB
+}
+
+func (s *NotificationStore) Create(ctx context.Context, n *Notification) error {
+	query := `
+		INSERT INTO notifications (user_id, actor_id, type, post_id, comment_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		n.UserID,
+		n.ActorID,
+		n.Type,
+		n.PostID,
+		n.CommentID,
+	).Scan(&n.ID, &n.CreatedAt)
+}
+
+// GetByUserID lists the notifications of a user, newest first. Those caused
+// by users they muted or are blocked with are left out.
+func (s *NotificationStore) GetByUserID(ctx context.Context, userID int64, q PaginatedQuery) ([]Notification, error) {
+	query := `
+		SELECT n.id, n.user_id, n.actor_id, n.type, n.post_id, n.comment_id, n.read_at, n.created_at,
+			u.id, u.username
+		FROM notifications AS n
+		INNER JOIN users AS u ON u.id = n.actor_id
+		WHERE n.user_id = $1
+			AND NOT ` + blockedBetween("$1", "n.actor_id") + `
+			AND NOT ` + mutedBy("$1", "n.actor_id") + `
+		ORDER BY n.created_at DESC, n.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		err := rows.Scan(
+			&n.ID,
+			&n.UserID,
+			&n.ActorID,
+			&n.Type,
+			&n.PostID,
+			&n.CommentID,
+			&n.ReadAt,
+			&n.CreatedAt,
+			&n.Actor.ID,
+			&n.Actor.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+func (s *NotificationStore) MarkAllRead(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE notifications SET read_at = NOW()
+		WHERE user_id = $1 AND read_at IS NULL
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
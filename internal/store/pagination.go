@@ -1,20 +1,58 @@
 package store
 
 import (
+	"encoding/base64"
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/yunsuk-jeung/social/internal/extract"
 )
 
+// PaginatedQuery is the limit/offset pagination used by plain listings
+type PaginatedQuery struct {
+	Limit  int `json:"limit" validate:"gte=1,lte=100"`
+	Offset int `json:"offset" validate:"gte=0"`
+}
+
+func (q PaginatedQuery) Parse(r *http.Request) (PaginatedQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = l
+	}
+
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return q, err
+		}
+		q.Offset = o
+	}
+
+	return q, nil
+}
+
+// PaginatedFeedQuery pages through a feed with the cursor of the previous
+// page. Offset is deprecated, it is only used without a cursor.
 type PaginatedFeedQuery struct {
-	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
-	Offset int      `json:"offset" validate:"gte=0"`
-	Sort   string   `json:"sort" validate:"oneof=asc desc"`
-	Tags   []string `json:"tags" validate:"max=5"`
-	Search string   `json:"search" validate:"max=100"`
-	Since  string   `json:"since"`
-	Until  string   `json:"until"`
+	Limit  int         `json:"limit" validate:"gte=1,lte=20"`
+	Cursor *FeedCursor `json:"-"`
+	Offset int         `json:"offset" validate:"gte=0"`
+	Sort   string      `json:"sort" validate:"oneof=asc desc"`
+	Tags   []string    `json:"tags" validate:"max=5"`
+	Search string      `json:"search" validate:"max=100"`
+	Since  string      `json:"since"`
+	Until  string      `json:"until"`
 }
 
 func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error) {
@@ -38,6 +76,16 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 		fq.Offset = l
 	}
 
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		c, err := ParseFeedCursor(cursor)
+		if err != nil {
+			return fq, err
+		}
+		fq.Cursor = c
+		fq.Offset = 0
+	}
+
 	sort := qs.Get("sort")
 	if sort != "" {
 		fq.Sort = sort
@@ -45,7 +93,9 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 
 	tags := qs.Get("tags")
 	if tags != "" {
-		fq.Tags = strings.Split(tags, ",")
+		for _, t := range strings.Split(tags, ",") {
+			fq.Tags = append(fq.Tags, extract.NormalizeTag(t))
+		}
 	}
 
 	search := qs.Get("search")
@@ -73,3 +123,58 @@ func parseTime(s string) string {
 	}
 	return t.Format(time.DateTime)
 }
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// FeedCursor is the position of the last entry of a feed page, the next page
+// starts after it. Entries are ordered by the time they were posted or
+// reposted, then by post ID. In ranked feeds they are ordered by Score then
+// post ID, and SortAt is the time the feed was ranked at.
+type FeedCursor struct {
+	SortAt time.Time
+	PostID int64
+	Score  *float64
+}
+
+// String encodes the cursor, clients are to pass it back as is
+func (c FeedCursor) String() string {
+	raw := strconv.FormatInt(c.SortAt.UnixNano(), 10) + ":" + strconv.FormatInt(c.PostID, 10)
+	if c.Score != nil {
+		raw += ":" + strconv.FormatFloat(*c.Score, 'g', -1, 64)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseFeedCursor(s string) (*FeedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, ErrInvalidCursor
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	postID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &FeedCursor{SortAt: time.Unix(0, nanos).UTC(), PostID: postID}
+
+	if len(parts) == 3 {
+		score, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil || math.IsNaN(score) || math.IsInf(score, 0) {
+			return nil, ErrInvalidCursor
+		}
+		c.Score = &score
+	}
+
+	return c, nil
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFeedCursor(t *testing.T) {
+	at := time.Unix(0, 1700000000123456789).UTC()
+	score := 1.2345678901234567
+
+	for _, c := range []FeedCursor{
+		{SortAt: at, PostID: 42},
+		{SortAt: at, PostID: 42, Score: &score},
+	} {
+		got, err := ParseFeedCursor(c.String())
+		if err != nil {
+			t.Fatalf("ParseFeedCursor(%q): %v", c.String(), err)
+		}
+
+		if !got.SortAt.Equal(c.SortAt) || got.PostID != c.PostID {
+			t.Errorf("ParseFeedCursor(%q) = %+v, want %+v", c.String(), got, c)
+		}
+
+		if (got.Score == nil) != (c.Score == nil) || (c.Score != nil && *got.Score != *c.Score) {
+			t.Errorf("ParseFeedCursor(%q) score = %v, want %v", c.String(), got.Score, c.Score)
+		}
+	}
+
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"bad base64", "not a cursor!"},
+		{"empty", ""},
+		{"missing id", encode("1700000000123456789")},
+		{"missing time", encode(":42")},
+		{"non numeric id", encode("1700000000123456789:abc")},
+		{"non numeric time", encode("yesterday:42")},
+		{"non numeric score", encode("1700000000123456789:42:high")},
+		{"infinite score", encode("1700000000123456789:42:+Inf")},
+		{"too many fields", encode("1700000000123456789:42:1:2")},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseFeedCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: ParseFeedCursor(%q) = %v, want ErrInvalidCursor", tt.name, tt.cursor, err)
+		}
+	}
+}
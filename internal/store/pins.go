@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var ErrPinLimit = errors.New("pinned posts limit reached")
+
+type PinStore struct {
+	db *sql.DB
+}
+
+// Pin pins a post to the profile of userID unless limit posts are pinned
+// already
+func (s *PinStore) Pin(ctx context.Context, userID, postID int64, limit int) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancle()
+
+		// concurrent pins of the same user wait here, so that they count the
+		// pins of one another
+		var locked int
+		err := tx.QueryRowContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&locked)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		query := `
+			INSERT INTO pinned_posts (user_id, post_id)
+			SELECT $1, $2
+			WHERE (SELECT count(*) FROM pinned_posts WHERE user_id = $1) < $3
+		`
+
+		res, err := tx.ExecContext(ctx, query, userID, postID, limit)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrPinLimit
+		}
+
+		return nil
+	})
+}
+
+func (s *PinStore) Unpin(ctx context.Context, userID, postID int64) error {
+	query := `
+		DELETE FROM pinned_posts WHERE user_id = $1 AND post_id = $2
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetByUserID lists the pinned posts of a user, most recently pinned first
+func (s *PinStore) GetByUserID(ctx context.Context, userID int64) ([]PostWithMetadata, error) {
+	query := `
+		SELECT ` + postWithMetadataColumns + `
+		FROM pinned_posts AS pp
+		INNER JOIN posts AS p ON p.id = pp.post_id
+		INNER JOIN users AS u ON p.user_id = u.id
+		WHERE pp.user_id = $1
+		ORDER BY pp.created_at DESC
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithMetadata{}
+	for rows.Next() {
+		p, err := scanPostWithMetadata(rows)
+		if err != nil {
+			return nil, err
+		}
+		p.Pinned = true
+		posts = append(posts, *p)
+	}
+
+	return posts, rows.Err()
+}
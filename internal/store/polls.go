@@ -0,0 +1,210 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrPollClosed = errors.New("the poll is closed")
+
+type Poll struct {
+	ID         int64        `json:"id"`
+	PostID     int64        `json:"post_id"`
+	Multiple   bool         `json:"multiple"`
+	ClosesAt   time.Time    `json:"closes_at"`
+	Closed     bool         `json:"closed"`
+	Voted      bool         `json:"voted"`
+	VoterCount *int         `json:"voter_count,omitempty"`
+	Options    []PollOption `json:"options"`
+}
+
+type PollOption struct {
+	ID    int64  `json:"id"`
+	Text  string `json:"text"`
+	Votes *int   `json:"votes,omitempty"`
+	Voted bool   `json:"voted"`
+}
+
+// HideResults removes the counts from a poll the viewer has not voted on
+// yet, unless the poll is closed
+func (p *Poll) HideResults() {
+	if p.Voted || p.Closed {
+		return
+	}
+
+	p.VoterCount = nil
+	for i := range p.Options {
+		p.Options[i].Votes = nil
+	}
+}
+
+type PollStore struct {
+	db *sql.DB
+}
+
+func (s *PollStore) Create(ctx context.Context, poll *Poll) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO polls (post_id, multiple, closes_at)
+			VALUES ($1, $2, $3) RETURNING id
+		`
+
+		ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancle()
+
+		err := tx.QueryRowContext(ctx, query, poll.PostID, poll.Multiple, poll.ClosesAt).Scan(&poll.ID)
+		if err != nil {
+			return err
+		}
+
+		for i := range poll.Options {
+			query := `
+				INSERT INTO poll_options (poll_id, position, text)
+				VALUES ($1, $2, $3) RETURNING id
+			`
+
+			o := &poll.Options[i]
+			if err := tx.QueryRowContext(ctx, query, poll.ID, i, o.Text).Scan(&o.ID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetByPostIDs loads the polls of the given posts with their counts and the
+// options viewerID voted for. Results are not hidden here.
+func (s *PollStore) GetByPostIDs(ctx context.Context, viewerID int64, postIDs []int64) (map[int64]*Poll, error) {
+	query := `
+		SELECT p.id, p.post_id, p.multiple, p.closes_at, p.closes_at <= NOW(),
+			(SELECT count(*) FROM poll_ballots AS b WHERE b.poll_id = p.id),
+			EXISTS (SELECT 1 FROM poll_ballots AS b WHERE b.poll_id = p.id AND b.user_id = $2)
+		FROM polls AS p
+		WHERE p.post_id = ANY($1)
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	polls := make(map[int64]*Poll)
+	byID := make(map[int64]*Poll)
+	var pollIDs []int64
+
+	for rows.Next() {
+		p := &Poll{Options: []PollOption{}}
+		var voters int
+
+		err := rows.Scan(&p.ID, &p.PostID, &p.Multiple, &p.ClosesAt, &p.Closed, &voters, &p.Voted)
+		if err != nil {
+			return nil, err
+		}
+		p.VoterCount = &voters
+
+		polls[p.PostID] = p
+		byID[p.ID] = p
+		pollIDs = append(pollIDs, p.ID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(pollIDs) == 0 {
+		return polls, nil
+	}
+
+	if err := s.loadOptions(ctx, viewerID, pollIDs, byID); err != nil {
+		return nil, err
+	}
+
+	return polls, nil
+}
+
+// Vote casts the single ballot of a user, it fails with ErrConflict when
+// the user already voted and ErrPollClosed once the poll has closed
+func (s *PollStore) Vote(ctx context.Context, pollID, userID int64, optionIDs []int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO poll_ballots (poll_id, user_id)
+			SELECT id, $2 FROM polls WHERE id = $1 AND closes_at > NOW()
+		`
+
+		ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancle()
+
+		res, err := tx.ExecContext(ctx, query, pollID, userID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrPollClosed
+		}
+
+		query = `
+			INSERT INTO poll_votes (poll_id, option_id, user_id)
+			SELECT $1, unnest($2::bigint[]), $3
+		`
+
+		_, err = tx.ExecContext(ctx, query, pollID, pq.Array(optionIDs), userID)
+		return err
+	})
+}
+
+func (s *PollStore) loadOptions(ctx context.Context, viewerID int64, pollIDs []int64, polls map[int64]*Poll) error {
+	query := `
+		SELECT o.id, o.poll_id, o.text,
+			(SELECT count(*) FROM poll_votes AS v WHERE v.option_id = o.id),
+			EXISTS (SELECT 1 FROM poll_votes AS v WHERE v.option_id = o.id AND v.user_id = $2)
+		FROM poll_options AS o
+		WHERE o.poll_id = ANY($1)
+		ORDER BY o.poll_id, o.position
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(pollIDs), viewerID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			o      PollOption
+			pollID int64
+			votes  int
+		)
+
+		if err := rows.Scan(&o.ID, &pollID, &o.Text, &votes, &o.Voted); err != nil {
+			return err
+		}
+		o.Votes = &votes
+
+		if p, ok := polls[pollID]; ok {
+			p.Options = append(p.Options, o)
+		}
+	}
+
+	return rows.Err()
+}
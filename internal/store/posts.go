@@ -4,96 +4,384 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/lib/pq"
 )
 
 type Post struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	UserID    int64     `json:"user_id"`
-	Tags      []string  `json:"tags"`
-	CreatedAt string    `json:"created_at"`
-	UpdatedAt string    `json:"updated_at"`
-	Version   int       `json:"version"`
-	Comments  []Comment `json:"comments"`
-	User      User      `json:"user"`
+	ID           int64    `json:"id"`
+	Content      string   `json:"content"`
+	ContentHTML  string   `json:"content_html,omitempty"`
+	Title        string   `json:"title"`
+	UserID       int64    `json:"user_id"`
+	Tags         []string `json:"tags"`
+	Language     string   `json:"language"`
+	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string   `json:"updated_at"`
+	Version      int      `json:"version"`
+	QuotedPostID *int64   `json:"quoted_post_id,omitempty"`
+	QuotedPost   *Post    `json:"quoted_post,omitempty"` // nil when the quoted post is gone or not visible
+	// set on the continuations of a thread, the head has neither
+	ContinuesPostID *int64        `json:"continues_post_id,omitempty"`
+	ThreadRootID    *int64        `json:"thread_root_id,omitempty"`
+	RepostCount     int           `json:"repost_count"`
+	QuoteCount      int           `json:"quote_count"`
+	Bookmarked      *bool         `json:"bookmarked,omitempty"`
+	Pinned          bool          `json:"pinned"`
+	Poll            *Poll         `json:"poll,omitempty"`
+	Comments        []Comment     `json:"comments"`
+	Attachments     []Attachment  `json:"attachments"`
+	LinkPreviews    []LinkPreview `json:"link_previews"`
+	Mentions        []Mention     `json:"mentions"`
+	User            User          `json:"user"`
 }
 
 type PostWithMetadata struct {
 	Post
-	CommentCount int `json:"comment_count"`
+	CommentCount int   `json:"comment_count"`
+	ThreadLength int   `json:"thread_length"`
+	RepostedBy   *User `json:"reposted_by,omitempty"`
+	// set in ranked feeds in debug mode
+	Ranking *RankingExplanation `json:"ranking,omitempty"`
 }
 
 type PostStore struct {
 	db *sql.DB
 }
 
-func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
-	query := `
-		SELECT
+// columns read by scanPostWithMetadata, p is the post and u its author
+const postWithMetadataColumns = `
 				p.id,
 				p.user_id,
 				p.title,
 				p.content,
+				p.content_html,
 				p.created_at,
 				p.version,
 				p.tags,
+				p.quoted_post_id,
+				p.continues_post_id,
+				p.thread_root_id,
 				u.username,
-				count(c.id) AS comments_count
-		FROM posts AS p
-		LEFT JOIN comments AS c ON p.id = c.post_id
-		LEFT JOIN users AS u ON p.user_id = u.id
-		INNER JOIN followers AS f ON p.user_id = f.follower_id OR p.user_id = $1 
-		WHERE 
-			f.user_id = $1 
-			AND (p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%')
+				u.display_name,
+				u.avatar_key,
+				(SELECT count(*) FROM comments AS c WHERE c.post_id = p.id) AS comments_count,
+				(SELECT count(*) FROM reposts AS r WHERE r.post_id = p.id) AS repost_count,
+				(SELECT count(*) FROM posts AS q WHERE q.quoted_post_id = p.id) AS quote_count,
+				1 + (SELECT count(*) FROM posts AS t WHERE t.thread_root_id = COALESCE(p.thread_root_id, p.id)) AS thread_length`
+
+// GetUserFeed merges the posts and reposts of the user and the users they
+// follow. A post shows up once, at its most recent appearance, and threads
+// only by their head. Users the viewer muted or is blocked with are left out,
+// as authors and as reposters, and so are reposted posts of private accounts
+// the viewer does not follow. The cursor of the next page is nil on the last
+// one.
+func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, *FeedCursor, error) {
+	authors := `
+		SELECT $1::bigint AS id
+		UNION
+		SELECT user_id FROM followers WHERE follower_id = $1 AND NOT ` + mutedBy("$1", "user_id")
+
+	return s.queryFeed(ctx, feedQuery(authors, fq.Sort == "asc"), userID, fq)
+}
+
+// GetListFeed is the feed of the members of a list, with the filters of the
+// user feed, as seen by viewerID. Members the viewer muted are left out as in
+// their own feed.
+func (s *PostStore) GetListFeed(ctx context.Context, listID, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, *FeedCursor, error) {
+	authors := `
+		SELECT user_id AS id FROM list_members WHERE list_id = $8 AND NOT ` + mutedBy("$1", "user_id")
+
+	return s.queryFeed(ctx, feedQuery(authors, fq.Sort == "asc"), viewerID, fq, listID)
+}
+
+// feedQuery selects the posts and reposts of the users selected by authors,
+// as seen by the viewer $1 and filtered by the search $4 and tags $5 of a
+// PaginatedFeedQuery. Entries come after the cursor ($6, $7) when it is set,
+// arguments after $7 are left to authors.
+func feedQuery(authors string, ascending bool) string {
+	order, after := "DESC", "<"
+	if ascending {
+		order, after = "ASC", ">"
+	}
+
+	return `
+		WITH authors AS (` + authors + `
+		), entries AS (
+			SELECT DISTINCT ON (post_id) post_id, sort_at, reposted_by
+			FROM (
+				SELECT p.id AS post_id, p.created_at AS sort_at, NULL::bigint AS reposted_by
+				FROM posts AS p
+				WHERE p.user_id IN (SELECT id FROM authors) AND p.thread_root_id IS NULL
+				UNION ALL
+				SELECT r.post_id, r.created_at, r.user_id
+				FROM reposts AS r
+				WHERE r.user_id IN (SELECT id FROM authors)
+			) AS e
+			ORDER BY post_id, sort_at DESC
+		)
+		SELECT ` + postWithMetadataColumns + `,
+				ru.id,
+				ru.username,
+				e.sort_at
+		FROM entries AS e
+		INNER JOIN posts AS p ON p.id = e.post_id
+		INNER JOIN users AS u ON p.user_id = u.id
+		LEFT JOIN users AS ru ON ru.id = e.reposted_by
+		WHERE
+			(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%')
 			AND ($5::varchar[] IS NULL OR p.tags @> $5)
-		GROUP BY p.id, u.username
-		ORDER BY p.created_at ` + fq.Sort + ` 
+			AND ($6::timestamptz IS NULL OR (e.sort_at, e.post_id) ` + after + ` ($6::timestamptz, $7::bigint))
+			AND NOT ` + blockedBetween("$1", "p.user_id") + `
+			AND NOT ` + mutedBy("$1", "p.user_id") + `
+			AND ` + visibleTo("u", "$1") + `
+		ORDER BY e.sort_at ` + order + `, e.post_id ` + order + `
 		LIMIT $2 OFFSET $3
 `
+}
+
+// queryFeed runs a feedQuery for viewerID, extra are the arguments of its
+// authors. One more entry than the limit is read to know whether there is a
+// next page.
+func (s *PostStore) queryFeed(ctx context.Context, query string, viewerID int64, fq PaginatedFeedQuery, extra ...any) ([]PostWithMetadata, *FeedCursor, error) {
 	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancle()
 
-	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags))
+	var (
+		cursorAt *time.Time
+		cursorID int64
+	)
+	if fq.Cursor != nil {
+		cursorAt = &fq.Cursor.SortAt
+		cursorID = fq.Cursor.PostID
+	}
+
+	args := append([]any{viewerID, fq.Limit + 1, fq.Offset, fq.Search, pq.Array(fq.Tags), cursorAt, cursorID}, extra...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	defer rows.Close()
 
-	var feed []PostWithMetadata
+	feed := []PostWithMetadata{}
+	var sortAt []time.Time
 
 	for rows.Next() {
-		var p PostWithMetadata
-		err := rows.Scan(
-			&p.ID,
-			&p.UserID,
-			&p.Title,
-			&p.Content,
-			&p.CreatedAt,
-			&p.Version,
-			pq.Array(&p.Tags),
-			&p.User.Username,
-			&p.CommentCount,
+		var (
+			reposterID   sql.NullInt64
+			reposterName sql.NullString
+			at           time.Time
 		)
+
+		p, err := scanPostWithMetadata(rows, &reposterID, &reposterName, &at)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if reposterID.Valid {
+			p.RepostedBy = &User{ID: reposterID.Int64, Username: reposterName.String}
+		}
+
+		feed = append(feed, *p)
+		sortAt = append(sortAt, at)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(feed) <= fq.Limit {
+		return feed, nil, nil
+	}
+
+	feed = feed[:fq.Limit]
+	last := len(feed) - 1
+
+	return feed, &FeedCursor{SortAt: sortAt[last], PostID: feed[last].ID}, nil
+}
+
+// GetByTag lists the posts carrying tag, newest first, threads only by their
+// head. Posts of users viewerID is blocked with or cannot see are left out.
+func (s *PostStore) GetByTag(ctx context.Context, tag string, viewerID int64, q PaginatedQuery) ([]PostWithMetadata, error) {
+	query := `
+		SELECT ` + postWithMetadataColumns + `
+		FROM posts AS p
+		INNER JOIN users AS u ON p.user_id = u.id
+		WHERE p.tags @> ARRAY[$1]::varchar[] AND p.thread_root_id IS NULL
+			AND NOT ` + blockedBetween("$4", "p.user_id") + `
+			AND ` + visibleTo("u", "$4") + `
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, tag, q.Limit, q.Offset, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithMetadata{}
+	for rows.Next() {
+		p, err := scanPostWithMetadata(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, *p)
+	}
+
+	return posts, rows.Err()
+}
+
+// GetByUserID lists the posts of a user, the posts they pinned come first
+// followed by the rest newest first. Threads show up by their head unless a
+// continuation was pinned.
+func (s *PostStore) GetByUserID(ctx context.Context, userID int64, q PaginatedQuery) ([]PostWithMetadata, error) {
+	query := `
+		SELECT ` + postWithMetadataColumns + `,
+				pp.post_id IS NOT NULL AS pinned
+		FROM posts AS p
+		INNER JOIN users AS u ON p.user_id = u.id
+		LEFT JOIN pinned_posts AS pp ON pp.post_id = p.id AND pp.user_id = p.user_id
+		WHERE p.user_id = $1 AND (p.thread_root_id IS NULL OR pp.post_id IS NOT NULL)
+		ORDER BY pinned DESC, pp.created_at DESC, p.created_at DESC, p.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithMetadata{}
+	for rows.Next() {
+		var pinned bool
+
+		p, err := scanPostWithMetadata(rows, &pinned)
 		if err != nil {
 			return nil, err
 		}
-		feed = append(feed, p)
+		p.Pinned = pinned
+
+		posts = append(posts, *p)
 	}
 
-	return feed, nil
+	return posts, rows.Err()
+}
+
+// GetThread returns the thread rootID is the head of, in order
+func (s *PostStore) GetThread(ctx context.Context, rootID int64) ([]PostWithMetadata, error) {
+	query := `
+		SELECT ` + postWithMetadataColumns + `
+		FROM posts AS p
+		INNER JOIN users AS u ON p.user_id = u.id
+		WHERE p.id = $1 OR p.thread_root_id = $1
+		ORDER BY p.created_at, p.id
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, rootID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithMetadata{}
+	for rows.Next() {
+		p, err := scanPostWithMetadata(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, *p)
+	}
+
+	return posts, rows.Err()
+}
+
+// GetByIDs loads the posts with the given ids, used to embed quoted posts
+func (s *PostStore) GetByIDs(ctx context.Context, ids []int64) (map[int64]*Post, error) {
+	query := `
+		SELECT ` + postWithMetadataColumns + `
+		FROM posts AS p
+		INNER JOIN users AS u ON p.user_id = u.id
+		WHERE p.id = ANY($1)
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make(map[int64]*Post, len(ids))
+	for rows.Next() {
+		p, err := scanPostWithMetadata(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts[p.ID] = &p.Post
+	}
+
+	return posts, rows.Err()
+}
+
+func scanPostWithMetadata(row rowScanner, extra ...any) (*PostWithMetadata, error) {
+	var p PostWithMetadata
+
+	dest := []any{
+		&p.ID,
+		&p.UserID,
+		&p.Title,
+		&p.Content,
+		&p.ContentHTML,
+		&p.CreatedAt,
+		&p.Version,
+		pq.Array(&p.Tags),
+		&p.QuotedPostID,
+		&p.ContinuesPostID,
+		&p.ThreadRootID,
+		&p.User.Username,
+		&p.User.DisplayName,
+		&p.User.AvatarKey,
+		&p.CommentCount,
+		&p.RepostCount,
+		&p.QuoteCount,
+		&p.ThreadLength,
+	}
+
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	p.User.ID = p.UserID
+
+	return &p, nil
 }
 
 func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 	query := `
-		SELECT id, user_id, title, content, created_at, updated_at, tags, version
-		FROM posts
-		WHERE id = $1
+		SELECT p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.updated_at, p.tags, p.version,
+			p.quoted_post_id, p.continues_post_id, p.thread_root_id, p.language::text,
+			u.username, u.display_name, u.avatar_key,
+			(SELECT count(*) FROM reposts WHERE post_id = p.id) AS repost_count,
+			(SELECT count(*) FROM posts AS q WHERE q.quoted_post_id = p.id) AS quote_count
+		FROM posts AS p
+		INNER JOIN users AS u ON u.id = p.user_id
+		WHERE p.id = $1
 		`
 
 	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -105,10 +393,20 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 		&post.UserID,
 		&post.Title,
 		&post.Content,
+		&post.ContentHTML,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		pq.Array(&post.Tags),
 		&post.Version,
+		&post.QuotedPostID,
+		&post.ContinuesPostID,
+		&post.ThreadRootID,
+		&post.Language,
+		&post.User.Username,
+		&post.User.DisplayName,
+		&post.User.AvatarKey,
+		&post.RepostCount,
+		&post.QuoteCount,
 	)
 
 	if err != nil {
@@ -120,14 +418,17 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 		}
 	}
 
+	post.User.ID = post.UserID
+
 	return &post, nil
 }
 
 // Create
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
 	query := `
-		INSERT INTO posts (content, title, user_id, tags)
-		VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at
+		INSERT INTO posts (content, content_html, title, user_id, tags, quoted_post_id, language, continues_post_id, thread_root_id)
+		VALUES ($1, $2, $3, $4, $5, $6, COALESCE(NULLIF($7, '')::regconfig, 'english'), $8, $9)
+		RETURNING id, created_at, updated_at, language::text
 	`
 
 	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -135,56 +436,93 @@ func (s *PostStore) Create(ctx context.Context, post *Post) error {
 
 	err := s.db.QueryRowContext(ctx, query,
 		post.Content,
+		post.ContentHTML,
 		post.Title,
 		post.UserID,
 		pq.Array(post.Tags),
+		post.QuotedPostID,
+		post.Language,
+		post.ContinuesPostID,
+		post.ThreadRootID,
 	).Scan(
 		&post.ID,
 		&post.CreatedAt,
 		&post.UpdatedAt,
+		&post.Language,
 	)
 
 	if err != nil {
+		// the post being continued already has a continuation
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
 		return err
 	}
 
 	return nil
 }
 
-// Delete
-func (s *PostStore) Delete(ctx context.Context, postID int64) error {
-	query := `
-		DELETE FROM posts WHERE id = $1
-	`
+// Delete deletes a post. The head of a thread hands over to the next post of
+// the thread, whose ID is returned, 0 when there is none.
+func (s *PostStore) Delete(ctx context.Context, postID int64) (int64, error) {
+	var promoted int64
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancle()
+
+		// the rest of the thread points at the promoted post before the
+		// head is gone, so that it is not split into posts of their own
+		promote := `
+			WITH next AS (
+				SELECT id FROM posts WHERE thread_root_id = $1
+				ORDER BY created_at, id
+				LIMIT 1
+			), promoted AS (
+				UPDATE posts SET thread_root_id = NULL
+				WHERE id = (SELECT id FROM next)
+				RETURNING id
+			), repointed AS (
+				UPDATE posts SET thread_root_id = (SELECT id FROM next)
+				WHERE thread_root_id = $1 AND id <> (SELECT id FROM next)
+			)
+			SELECT id FROM promoted
+		`
 
-	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancle()
+		err := tx.QueryRowContext(ctx, promote, postID).Scan(&promoted)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
 
-	res, err := s.db.ExecContext(ctx, query, postID)
+		res, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE id = $1`, postID)
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		return err
-	}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
 
-	rows, err := res.RowsAffected()
+		if rows == 0 {
+			return ErrNotFound
+		}
 
+		return nil
+	})
 	if err != nil {
-		return err
-	}
-
-	if rows == 0 {
-		return ErrNotFound
+		return 0, err
 	}
 
-	return nil
+	return promoted, nil
 }
 
 // Update
 func (s *PostStore) Update(ctx context.Context, post *Post) error {
 	query := `
 		UPDATE posts
-		SET title = $1, content = $2, version = version +1
-		WHERE id = $3 AND version = $4
+		SET title = $1, content = $2, content_html = $3, tags = $4, version = version +1
+		WHERE id = $5 AND version = $6
 		RETURNING version
 	`
 
@@ -196,14 +534,17 @@ func (s *PostStore) Update(ctx context.Context, post *Post) error {
 		query,
 		post.Title,
 		post.Content,
+		post.ContentHTML,
+		pq.Array(post.Tags),
 		post.ID,
 		post.Version,
 	).Scan(&post.Version)
 
 	if err != nil {
 		switch {
+		// the post was edited or deleted since it was read
 		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
+			return ErrEditConflict
 		default:
 			return err
 		}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// FeedCandidate is an entry of a home timeline with the signals it is ranked
+// by. Interactions counts the recent comments, reposts, quotes and bookmarks
+// of the viewer on posts of the author, MatchedTags the tags of the post the
+// viewer recently engaged with.
+type FeedCandidate struct {
+	FeedEntry
+	Comments     int
+	Reposts      int
+	Quotes       int
+	Interactions int
+	Tags         int
+	MatchedTags  int
+}
+
+// RankingExplanation breaks the score of a post in a ranked feed down by
+// signal, it is only sent in debug mode
+type RankingExplanation struct {
+	Score   float64         `json:"score"`
+	Factors []RankingFactor `json:"factors"`
+}
+
+// RankingFactor is a signal of a ranked post, its contribution to the score
+// is its value times its weight
+type RankingFactor struct {
+	Name         string  `json:"name"`
+	Value        float64 `json:"value"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"`
+}
+
+// FeedCandidateQuery selects the entries of a home timeline made between
+// Since and Until to rank. The interests of the user are read from what they
+// did after InterestSince.
+type FeedCandidateQuery struct {
+	Since         time.Time
+	Until         time.Time
+	InterestSince time.Time
+	Limit         int
+}
+
+// GetFeedCandidates lists the entries of the home timeline of userID, newest
+// first, with their ranking signals. Posts the user may not see are left out.
+func (s *PostStore) GetFeedCandidates(ctx context.Context, userID int64, q FeedCandidateQuery) ([]FeedCandidate, error) {
+	query := `
+		WITH authors AS (
+			SELECT $1::bigint AS id
+			UNION
+			SELECT user_id FROM followers WHERE follower_id = $1 AND NOT ` + mutedBy("$1", "user_id") + `
+		), entries AS (
+			SELECT DISTINCT ON (post_id) post_id, sort_at
+			FROM (
+				SELECT p.id AS post_id, p.created_at AS sort_at
+				FROM posts AS p
+				WHERE p.user_id IN (SELECT id FROM authors) AND p.thread_root_id IS NULL
+					AND p.created_at > $2 AND p.created_at <= $5
+				UNION ALL
+				SELECT r.post_id, r.created_at
+				FROM reposts AS r
+				WHERE r.user_id IN (SELECT id FROM authors) AND r.created_at > $2 AND r.created_at <= $5
+			) AS e
+			ORDER BY post_id, sort_at DESC
+		), engaged AS (
+			SELECT post_id FROM comments WHERE user_id = $1 AND created_at > $3
+			UNION ALL
+			SELECT post_id FROM reposts WHERE user_id = $1 AND created_at > $3
+			UNION ALL
+			SELECT quoted_post_id FROM posts WHERE user_id = $1 AND quoted_post_id IS NOT NULL AND created_at > $3
+			UNION ALL
+			SELECT post_id FROM bookmarks WHERE user_id = $1 AND created_at > $3
+		), affinities AS (
+			SELECT p.user_id, count(*) AS interactions
+			FROM engaged AS g
+			INNER JOIN posts AS p ON p.id = g.post_id
+			WHERE p.user_id <> $1
+			GROUP BY p.user_id
+		), interests AS (
+			SELECT DISTINCT unnest(p.tags) AS tag
+			FROM posts AS p
+			WHERE p.id IN (SELECT post_id FROM engaged)
+				OR (p.user_id = $1 AND p.created_at > $3)
+		)
+		SELECT
+			e.post_id,
+			e.sort_at,
+			(SELECT count(*) FROM comments AS c WHERE c.post_id = p.id),
+			(SELECT count(*) FROM reposts AS r WHERE r.post_id = p.id),
+			(SELECT count(*) FROM posts AS q WHERE q.quoted_post_id = p.id),
+			COALESCE(a.interactions, 0),
+			COALESCE(cardinality(p.tags), 0),
+			(SELECT count(*) FROM unnest(p.tags) AS t (tag) WHERE t.tag IN (SELECT tag FROM interests))
+		FROM entries AS e
+		INNER JOIN posts AS p ON p.id = e.post_id
+		INNER JOIN users AS u ON u.id = p.user_id
+		LEFT JOIN affinities AS a ON a.user_id = p.user_id
+		WHERE NOT ` + blockedBetween("$1", "p.user_id") + `
+			AND NOT ` + mutedBy("$1", "p.user_id") + `
+			AND ` + visibleTo("u", "$1") + `
+		ORDER BY e.sort_at DESC, e.post_id DESC
+		LIMIT $4
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, q.Since, q.InterestSince, q.Limit, q.Until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []FeedCandidate{}
+	for rows.Next() {
+		var c FeedCandidate
+		err := rows.Scan(
+			&c.PostID,
+			&c.SortAt,
+			&c.Comments,
+			&c.Reposts,
+			&c.Quotes,
+			&c.Interactions,
+			&c.Tags,
+			&c.MatchedTags,
+		)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
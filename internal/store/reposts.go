@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type RepostStore struct {
+	db *sql.DB
+}
+
+// Create reposts a post and returns the time of the repost
+func (s *RepostStore) Create(ctx context.Context, userID, postID int64) (time.Time, error) {
+	query := `
+		INSERT INTO reposts (user_id, post_id) VALUES ($1, $2) RETURNING created_at
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	var createdAt time.Time
+	err := s.db.QueryRowContext(ctx, query, userID, postID).Scan(&createdAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return createdAt, ErrConflict
+		}
+	}
+	return createdAt, err
+}
+
+func (s *RepostStore) Delete(ctx context.Context, userID, postID int64) error {
+	query := `
+		DELETE FROM reposts WHERE user_id = $1 AND post_id = $2
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/yunsuk-jeung/social/internal/search"
+)
+
+// what can be searched with SearchQuery.Types
+const (
+	SearchTypePosts    = "posts"
+	SearchTypeComments = "comments"
+	SearchTypeUsers    = "users"
+)
+
+type SearchQuery struct {
+	Query    string   `json:"q" validate:"required,max=200"`
+	Language string   `json:"lang" validate:"search_language"`
+	Types    []string `json:"type" validate:"dive,oneof=posts comments users"`
+	Limit    int      `json:"limit" validate:"gte=1,lte=50"`
+	Offset   int      `json:"offset" validate:"gte=0"`
+	// results by users the viewer is blocked with, and posts of private
+	// accounts they do not follow, are left out
+	ViewerID int64 `json:"-"`
+}
+
+func (q SearchQuery) Parse(r *http.Request) (SearchQuery, error) {
+	qs := r.URL.Query()
+
+	q.Query = strings.TrimSpace(qs.Get("q"))
+
+	if lang := qs.Get("lang"); lang != "" {
+		q.Language = lang
+	}
+
+	if types := qs.Get("type"); types != "" {
+		q.Types = strings.Split(types, ",")
+	}
+
+	pq, err := PaginatedQuery{Limit: q.Limit, Offset: q.Offset}.Parse(r)
+	if err != nil {
+		return q, err
+	}
+	q.Limit = pq.Limit
+	q.Offset = pq.Offset
+
+	return q, nil
+}
+
+// Includes reports whether results of type t were asked for, all types are
+// searched when none is given
+func (q SearchQuery) Includes(t string) bool {
+	if len(q.Types) == 0 {
+		return true
+	}
+	for _, qt := range q.Types {
+		if qt == t {
+			return true
+		}
+	}
+	return false
+}
+
+type SearchHighlights struct {
+	Title   string `json:"title,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type PostSearchResult struct {
+	PostWithMetadata
+	Rank       float64          `json:"rank"`
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+type CommentSearchResult struct {
+	Comment
+	Rank       float64          `json:"rank"`
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+type UserSearchResult struct {
+	ID       int64   `json:"id"`
+	Username string  `json:"username"`
+	Rank     float64 `json:"rank"`
+}
+
+type SearchStore struct {
+	db *sql.DB
+}
+
+// Posts ranks posts by relevance, a match in the title weighs more than one
+// in the content
+func (s *SearchStore) Posts(ctx context.Context, q SearchQuery) ([]PostSearchResult, error) {
+	tsquery := search.TSQuery(q.Query)
+	if tsquery == "" {
+		return []PostSearchResult{}, nil
+	}
+
+	query := `
+		WITH query AS (SELECT to_tsquery($1::regconfig, $2) AS q)
+		SELECT ` + postWithMetadataColumns + `,
+				ts_rank_cd(p.search_vector, query.q) AS rank,
+				ts_headline(p.language, p.title, query.q, $3),
+				ts_headline(p.language, p.content, query.q, $3)
+		FROM posts AS p
+		INNER JOIN users AS u ON p.user_id = u.id
+		CROSS JOIN query
+		WHERE p.search_vector @@ query.q AND NOT ` + blockedBetween("$6", "p.user_id") + `
+			AND ` + visibleTo("u", "$6") + `
+		ORDER BY rank DESC, p.created_at DESC, p.id DESC
+		LIMIT $4 OFFSET $5
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, q.Language, tsquery, search.HeadlineOptions, q.Limit, q.Offset, q.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []PostSearchResult{}
+	for rows.Next() {
+		var res PostSearchResult
+
+		p, err := scanPostWithMetadata(rows, &res.Rank, &res.Highlights.Title, &res.Highlights.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		res.PostWithMetadata = *p
+		res.Highlights.Title = search.Highlight(res.Highlights.Title)
+		res.Highlights.Content = search.Highlight(res.Highlights.Content)
+		results = append(results, res)
+	}
+
+	return results, rows.Err()
+}
+
+// comments are indexed with a single text search configuration, they have no
+// language of their own
+const commentSearchLanguage = "english"
+
+// Comments ranks comments by relevance. They are always searched as english,
+// whatever the language of the query, to match how they are indexed.
+func (s *SearchStore) Comments(ctx context.Context, q SearchQuery) ([]CommentSearchResult, error) {
+	tsquery := search.TSQuery(q.Query)
+	if tsquery == "" {
+		return []CommentSearchResult{}, nil
+	}
+
+	query := `
+		WITH query AS (SELECT to_tsquery($1::regconfig, $2) AS q)
+		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, u.username, u.display_name, u.avatar_key,
+				ts_rank_cd(c.search_vector, query.q) AS rank,
+				ts_headline($1::regconfig, c.content, query.q, $3)
+		FROM comments AS c
+		INNER JOIN users AS u ON c.user_id = u.id
+		INNER JOIN posts AS p ON p.id = c.post_id
+		INNER JOIN users AS pu ON pu.id = p.user_id
+		CROSS JOIN query
+		WHERE c.search_vector @@ query.q AND NOT ` + blockedBetween("$6", "c.user_id") + `
+			AND NOT ` + blockedBetween("$6", "p.user_id") + `
+			AND ` + visibleTo("pu", "$6") + `
+		ORDER BY rank DESC, c.created_at DESC, c.id DESC
+		LIMIT $4 OFFSET $5
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, commentSearchLanguage, tsquery, search.HeadlineOptions, q.Limit, q.Offset, q.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []CommentSearchResult{}
+	for rows.Next() {
+		var res CommentSearchResult
+
+		err := rows.Scan(
+			&res.ID,
+			&res.PostID,
+			&res.UserID,
+			&res.Content,
+			&res.CreatedAt,
+			&res.User.Username,
+			&res.User.DisplayName,
+			&res.User.AvatarKey,
+			&res.Rank,
+			&res.Highlights.Content,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		res.User.ID = res.UserID
+		res.Highlights.Content = search.Highlight(res.Highlights.Content)
+		results = append(results, res)
+	}
+
+	return results, rows.Err()
+}
+
+// Users matches usernames, they are indexed without a language so the
+// search language does not apply
+func (s *SearchStore) Users(ctx context.Context, q SearchQuery) ([]UserSearchResult, error) {
+	tsquery := search.TSQuery(q.Query)
+	if tsquery == "" {
+		return []UserSearchResult{}, nil
+	}
+
+	query := `
+		WITH query AS (SELECT to_tsquery('simple', $1) AS q)
+		SELECT u.id, u.username, ts_rank_cd(u.search_vector, query.q) AS rank
+		FROM users AS u
+		CROSS JOIN query
+		WHERE u.search_vector @@ query.q AND u.is_active AND NOT ` + blockedBetween("$4", "u.id") + `
+		ORDER BY rank DESC, u.username
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, tsquery, q.Limit, q.Offset, q.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []UserSearchResult{}
+	for rows.Next() {
+		var res UserSearchResult
+		if err := rows.Scan(&res.ID, &res.Username, &res.Rank); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+
+	return results, rows.Err()
+}
@@ -10,6 +10,7 @@ import (
 var (
 	ErrNotFound          = errors.New("resource not found")
 	ErrConflict          = errors.New("resource already exists")
+	ErrEditConflict      = errors.New("resource was modified by another request")
 	QueryTimeoutDuration = time.Second * 5
 )
 
@@ -17,9 +18,17 @@ type Storage struct {
 	Posts interface {
 		GetByID(context.Context, int64) (*Post, error)
 		Create(context.Context, *Post) error
-		Delete(context.Context, int64) error
+		Delete(context.Context, int64) (int64, error)
 		Update(context.Context, *Post) error
-		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, *FeedCursor, error)
+		GetListFeed(ctx context.Context, listID, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, *FeedCursor, error)
+		GetFeedEntries(ctx context.Context, userID int64, q FeedEntryQuery) ([]FeedEntry, error)
+		GetByFeedEntries(ctx context.Context, viewerID int64, entries []FeedEntry) ([]PostWithMetadata, error)
+		GetFeedCandidates(ctx context.Context, userID int64, q FeedCandidateQuery) ([]FeedCandidate, error)
+		GetByTag(ctx context.Context, tag string, viewerID int64, q PaginatedQuery) ([]PostWithMetadata, error)
+		GetByIDs(context.Context, []int64) (map[int64]*Post, error)
+		GetByUserID(context.Context, int64, PaginatedQuery) ([]PostWithMetadata, error)
+		GetThread(context.Context, int64) ([]PostWithMetadata, error)
 	}
 	Users interface {
 		GetByID(context.Context, int64) (*User, error)
@@ -28,27 +37,139 @@ type Storage struct {
 		CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error
 		Activate(context.Context, string) error
 		Delete(context.Context, int64) error
+		UpdateProfile(context.Context, *User) error
+		SetAvatar(ctx context.Context, userID int64, key string) (string, error)
+		ChangeUsername(ctx context.Context, userID int64, username string, policy UsernamePolicy) error
+		Search(context.Context, UserSearchQuery) ([]UserMatch, error)
+		LookupUsername(ctx context.Context, username string) (int64, string, error)
 	}
 	Comments interface {
-		GetByPostID(ctx context.Context, postID int64) ([]Comment, error)
+		GetByPostID(ctx context.Context, postID, viewerID int64) ([]Comment, error)
 		Create(context.Context, *Comment) error
 	}
 	Followers interface {
 		Follow(ctx context.Context, followerId, userID int64) error
 		Unfollow(ctx context.Context, followerId, userID int64) error
+		GetFollowers(ctx context.Context, userID, viewerID int64, q PaginatedQuery) ([]FollowListUser, error)
+		GetFollowing(ctx context.Context, userID, viewerID int64, q PaginatedQuery) ([]FollowListUser, error)
+		GetCounts(ctx context.Context, userID int64) (*FollowCounts, error)
+		HasFollowers(ctx context.Context, userID int64, count int) (bool, error)
+		GetFollowerIDs(ctx context.Context, userID, afterID int64, limit int) ([]int64, error)
+		GetRelationship(ctx context.Context, viewerID, userID int64) (*Relationship, error)
+		RequestFollow(ctx context.Context, requesterID, userID int64) error
+		GetRequests(ctx context.Context, userID int64, q PaginatedQuery) ([]FollowRequest, error)
+		ApproveRequest(ctx context.Context, userID, requesterID int64) error
+		RejectRequest(ctx context.Context, userID, requesterID int64) error
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
 	}
+	Attachments interface {
+		GetByID(context.Context, int64) (*Attachment, error)
+		GetByPostIDs(context.Context, []int64) (map[int64][]Attachment, error)
+		Create(context.Context, *Attachment) error
+		UpdateImage(context.Context, *Attachment) error
+		Delete(context.Context, int64) error
+	}
+	Mentions interface {
+		SetForPost(context.Context, *Post, []string) ([]Mention, []int64, error)
+		SetForComment(context.Context, *Comment, []string) ([]Mention, []int64, error)
+		GetByPostID(context.Context, int64) ([]Mention, error)
+		GetForComments(context.Context, int64) (map[int64][]Mention, error)
+	}
+	Notifications interface {
+		Create(context.Context, *Notification) error
+		GetByUserID(context.Context, int64, PaginatedQuery) ([]Notification, error)
+		MarkAllRead(context.Context, int64) error
+	}
+	Tags interface {
+		IncrementUsage(context.Context, []string) error
+		GetTrending(context.Context, time.Duration, int) ([]TagCount, error)
+		Prune(context.Context, time.Duration) error
+	}
+	Reposts interface {
+		Create(ctx context.Context, userID, postID int64) (time.Time, error)
+		Delete(ctx context.Context, userID, postID int64) error
+	}
+	Bookmarks interface {
+		Save(ctx context.Context, userID, postID int64, folder *string) error
+		Delete(ctx context.Context, userID, postID int64) error
+		GetByUserID(ctx context.Context, userID int64, folder string, q PaginatedQuery) ([]PostWithMetadata, error)
+		GetBookmarked(ctx context.Context, userID int64, postIDs []int64) (map[int64]bool, error)
+		GetFolders(ctx context.Context, userID int64) ([]BookmarkFolder, error)
+	}
+	Polls interface {
+		Create(context.Context, *Poll) error
+		GetByPostIDs(ctx context.Context, viewerID int64, postIDs []int64) (map[int64]*Poll, error)
+		Vote(ctx context.Context, pollID, userID int64, optionIDs []int64) error
+	}
+	Pins interface {
+		Pin(ctx context.Context, userID, postID int64, limit int) error
+		Unpin(ctx context.Context, userID, postID int64) error
+		GetByUserID(context.Context, int64) ([]PostWithMetadata, error)
+	}
+	Links interface {
+		SetForPost(ctx context.Context, postID int64, urls []string) error
+		GetPreview(ctx context.Context, url string, maxAge time.Duration) (*LinkPreview, error)
+		SavePreview(context.Context, *LinkPreview) error
+		GetPreviewsByPostIDs(context.Context, []int64) (map[int64][]LinkPreview, error)
+	}
+	Search interface {
+		Posts(context.Context, SearchQuery) ([]PostSearchResult, error)
+		Comments(context.Context, SearchQuery) ([]CommentSearchResult, error)
+		Users(context.Context, SearchQuery) ([]UserSearchResult, error)
+	}
+	Analytics interface {
+		RecordImpressions(context.Context, []Impression) error
+		Rollup(ctx context.Context, hourlyRetention time.Duration) error
+		GetByUserID(context.Context, int64, AnalyticsQuery) (*Analytics, error)
+	}
+	Blocks interface {
+		Block(ctx context.Context, blockerID, blockedID int64) error
+		Unblock(ctx context.Context, blockerID, blockedID int64) error
+		Mute(ctx context.Context, muterID, mutedID int64) error
+		Unmute(ctx context.Context, muterID, mutedID int64) error
+		IsBlocked(ctx context.Context, userID, otherID int64) (bool, error)
+		GetBlocked(ctx context.Context, blockerID int64, q PaginatedQuery) ([]User, error)
+		GetMuted(ctx context.Context, muterID int64, q PaginatedQuery) ([]User, error)
+	}
+	Suggestions interface {
+		Refresh(ctx context.Context, perUser int) error
+		GetByUserID(ctx context.Context, userID int64, q PaginatedQuery) ([]Suggestion, error)
+	}
+	Lists interface {
+		Create(context.Context, *List) error
+		GetByID(context.Context, int64) (*List, error)
+		GetByUserID(ctx context.Context, userID int64, includePrivate bool, q PaginatedQuery) ([]List, error)
+		Update(context.Context, *List) error
+		Delete(context.Context, int64) error
+		AddMember(ctx context.Context, listID, userID int64, limit int) error
+		RemoveMember(ctx context.Context, listID, userID int64) error
+		GetMembers(ctx context.Context, listID, viewerID int64, q PaginatedQuery) ([]User, error)
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostStore{db},
-		Users:     &UserStore{db},
-		Comments:  &CommentStore{db},
-		Followers: &FollowerStore{db},
-		Roles:     &RoleStore{db},
+		Posts:         &PostStore{db},
+		Users:         &UserStore{db},
+		Comments:      &CommentStore{db},
+		Followers:     &FollowerStore{db},
+		Roles:         &RoleStore{db},
+		Attachments:   &AttachmentStore{db},
+		Mentions:      &MentionStore{db},
+		Notifications: &NotificationStore{db},
+		Tags:          &TagStore{db},
+		Reposts:       &RepostStore{db},
+		Bookmarks:     &BookmarkStore{db},
+		Polls:         &PollStore{db},
+		Pins:          &PinStore{db},
+		Links:         &LinkStore{db},
+		Search:        &SearchStore{db},
+		Analytics:     &AnalyticsStore{db},
+		Blocks:        &BlockStore{db},
+		Suggestions:   &SuggestionStore{db},
+		Lists:         &ListStore{db},
 	}
 }
 
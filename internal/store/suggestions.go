@@ -0,0 +1,275 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// why a user was suggested, the strongest signal wins
+const (
+	SuggestionReasonFollowedByFollows = "followed_by_people_you_follow"
+	SuggestionReasonSharedInterests   = "shared_interests"
+	SuggestionReasonPopular           = "popular"
+)
+
+const (
+	// tags and activity are taken from the posts of this period
+	suggestionActivityWindow = time.Hour * 24 * 30
+	// the most followed active accounts, suggested to everyone
+	popularSuggestions = 50
+	// users whose suggestions are refreshed together
+	suggestionRefreshBatch = 500
+	// the most active authors of a tag, the ones suggested for it
+	suggestionTagAuthors = 100
+)
+
+type Suggestion struct {
+	User
+	Reason string `json:"reason"`
+}
+
+type SuggestionStore struct {
+	db *sql.DB
+}
+
+// Refresh recomputes the suggestions of every user, a batch of users at a
+// time, and keeps the perUser best ones. Accounts followed by the ones a user
+// follows weigh the most, then accounts posting about the same tags, then
+// popular accounts that posted recently. Users already followed or requested,
+// blocked either way or muted are never suggested.
+func (s *SuggestionStore) Refresh(ctx context.Context, perUser int) error {
+	popularIDs, popularScores, err := s.popular(ctx)
+	if err != nil {
+		return err
+	}
+
+	var after int64
+	for {
+		userIDs, err := s.activeUserIDs(ctx, after, suggestionRefreshBatch)
+		if err != nil {
+			return err
+		}
+
+		if len(userIDs) == 0 {
+			return nil
+		}
+
+		if err := s.refreshBatch(ctx, userIDs, perUser, popularIDs, popularScores); err != nil {
+			return err
+		}
+
+		after = userIDs[len(userIDs)-1]
+	}
+}
+
+// popularAccounts selects the id and score of the limit most followed active
+// accounts that posted within window seconds
+func popularAccounts(window, limit string) string {
+	return `
+		SELECT f.user_id AS id, ln(1 + count(*)::float8) AS score
+		FROM followers AS f
+		INNER JOIN users AS pu ON pu.id = f.user_id AND pu.is_active
+		WHERE EXISTS (
+			SELECT 1 FROM posts AS p
+			WHERE p.user_id = f.user_id AND p.created_at >= NOW() - ` + window + ` * interval '1 second'
+		)
+		GROUP BY f.user_id
+		ORDER BY score DESC, f.user_id
+		LIMIT ` + limit
+}
+
+func (s *SuggestionStore) popular(ctx context.Context) ([]int64, []float64, error) {
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, popularAccounts("$1", "$2"), suggestionActivityWindow.Seconds(), popularSuggestions)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var (
+		ids    []int64
+		scores []float64
+	)
+	for rows.Next() {
+		var (
+			id    int64
+			score float64
+		)
+		if err := rows.Scan(&id, &score); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		scores = append(scores, score)
+	}
+
+	return ids, scores, rows.Err()
+}
+
+func (s *SuggestionStore) activeUserIDs(ctx context.Context, afterID int64, limit int) ([]int64, error) {
+	query := `
+		SELECT id FROM users WHERE is_active AND id > $1 ORDER BY id LIMIT $2
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// refreshBatch upserts the suggestions of userIDs and drops the ones they no
+// longer get. Shared interests are only looked for among the most active
+// authors of a tag, so that popular tags do not blow up the join.
+func (s *SuggestionStore) refreshBatch(ctx context.Context, userIDs []int64, perUser int, popularIDs []int64, popularScores []float64) error {
+	query := `
+		WITH follows_of_follows AS (
+			SELECT f1.follower_id AS user_id, f2.user_id AS suggested_id,
+				count(DISTINCT f1.user_id) * 3.0::float8 AS score,
+				'` + SuggestionReasonFollowedByFollows + `' AS reason
+			FROM followers AS f1
+			INNER JOIN followers AS f2 ON f2.follower_id = f1.user_id
+			WHERE f1.follower_id = ANY($1)
+			GROUP BY 1, 2
+		), batch_tags AS (
+			SELECT DISTINCT p.user_id, t.tag
+			FROM posts AS p, unnest(p.tags) AS t(tag)
+			WHERE p.user_id = ANY($1) AND p.created_at >= NOW() - $2 * interval '1 second'
+		), tag_authors AS (
+			SELECT tag, user_id
+			FROM (
+				SELECT t.tag, p.user_id,
+					row_number() OVER (PARTITION BY t.tag ORDER BY count(*) DESC, p.user_id) AS n
+				FROM posts AS p, unnest(p.tags) AS t(tag)
+				WHERE p.created_at >= NOW() - $2 * interval '1 second'
+					AND t.tag IN (SELECT tag FROM batch_tags)
+				GROUP BY t.tag, p.user_id
+			) AS a
+			WHERE n <= $6
+		), shared_tags AS (
+			SELECT a.user_id, b.user_id AS suggested_id,
+				count(*) * 2.0::float8 AS score,
+				'` + SuggestionReasonSharedInterests + `' AS reason
+			FROM batch_tags AS a
+			INNER JOIN tag_authors AS b ON b.tag = a.tag AND b.user_id <> a.user_id
+			GROUP BY 1, 2
+		), candidates AS (
+			SELECT user_id, suggested_id, score, reason FROM follows_of_follows
+			UNION ALL
+			SELECT user_id, suggested_id, score, reason FROM shared_tags
+			UNION ALL
+			SELECT b.user_id, p.suggested_id, p.score, '` + SuggestionReasonPopular + `'
+			FROM unnest($1::bigint[]) AS b(user_id)
+			CROSS JOIN unnest($4::bigint[], $5::float8[]) AS p(suggested_id, score)
+		), scored AS (
+			SELECT user_id, suggested_id, sum(score) AS score,
+				(array_agg(reason ORDER BY score DESC))[1] AS reason
+			FROM candidates
+			WHERE user_id <> suggested_id
+			GROUP BY 1, 2
+		), ranked AS (
+			SELECT s.user_id, s.suggested_id, s.score, s.reason,
+				row_number() OVER (PARTITION BY s.user_id ORDER BY s.score DESC, s.suggested_id) AS n
+			FROM scored AS s
+			INNER JOIN users AS su ON su.id = s.suggested_id AND su.is_active
+			WHERE ` + suggestable("s.user_id", "s.suggested_id") + `
+		), fresh AS (
+			INSERT INTO user_suggestions (user_id, suggested_id, score, reason)
+			SELECT user_id, suggested_id, score, reason
+			FROM ranked
+			WHERE n <= $3
+			ON CONFLICT (user_id, suggested_id) DO UPDATE
+			SET score = EXCLUDED.score, reason = EXCLUDED.reason, created_at = NOW()
+			RETURNING user_id, suggested_id
+		)
+		DELETE FROM user_suggestions AS us
+		WHERE us.user_id = ANY($1)
+			AND NOT EXISTS (SELECT 1 FROM fresh AS f WHERE f.user_id = us.user_id AND f.suggested_id = us.suggested_id)
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	_, err := s.db.ExecContext(
+		ctx,
+		query,
+		pq.Array(userIDs),
+		suggestionActivityWindow.Seconds(),
+		perUser,
+		pq.Array(popularIDs),
+		pq.Array(popularScores),
+		suggestionTagAuthors,
+	)
+	return err
+}
+
+// GetByUserID lists the suggestions of a user, best first. They are checked
+// again since the last refresh, a user followed or blocked meanwhile is
+// dropped. Users the last refresh has not reached yet get the popular
+// accounts.
+func (s *SuggestionStore) GetByUserID(ctx context.Context, userID int64, q PaginatedQuery) ([]Suggestion, error) {
+	query := `
+		WITH suggested AS (
+			SELECT s.suggested_id AS id, s.score, s.reason
+			FROM user_suggestions AS s
+			WHERE s.user_id = $1
+			UNION ALL
+			SELECT pa.id, pa.score, '` + SuggestionReasonPopular + `'
+			FROM (` + popularAccounts("$4", "$5") + `) AS pa
+			WHERE NOT EXISTS (SELECT 1 FROM user_suggestions WHERE user_id = $1)
+		)
+		SELECT u.id, u.username, u.display_name, u.bio, u.avatar_key, sg.reason
+		FROM suggested AS sg
+		INNER JOIN users AS u ON u.id = sg.id
+		WHERE u.id <> $1 AND u.is_active AND ` + suggestable("$1", "u.id") + `
+		ORDER BY sg.score DESC, u.id
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, q.Limit, q.Offset, suggestionActivityWindow.Seconds(), popularSuggestions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []Suggestion{}
+	for rows.Next() {
+		var sg Suggestion
+		if err := rows.Scan(&sg.ID, &sg.Username, &sg.DisplayName, &sg.Bio, &sg.AvatarKey, &sg.Reason); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, sg)
+	}
+
+	return suggestions, rows.Err()
+}
+
+// suggestable is a SQL condition that holds when candidate may be suggested
+// to user
+func suggestable(user, candidate string) string {
+	return `NOT EXISTS (SELECT 1 FROM followers AS sf WHERE sf.user_id = ` + candidate + ` AND sf.follower_id = ` + user + `)
+		AND NOT EXISTS (SELECT 1 FROM follow_requests AS sr WHERE sr.user_id = ` + candidate + ` AND sr.requester_id = ` + user + `)
+		AND NOT ` + blockedBetween(user, candidate) + `
+		AND NOT ` + mutedBy(user, candidate)
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type TagStore struct {
+	db *sql.DB
+}
+
+// IncrementUsage counts one use of each tag in the current hourly bucket
+func (s *TagStore) IncrementUsage(ctx context.Context, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO tag_usages (tag, bucket, count)
+		SELECT unnest($1::varchar[]), date_trunc('hour', NOW()), 1
+		ON CONFLICT (tag, bucket) DO UPDATE SET count = tag_usages.count + 1
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	_, err := s.db.ExecContext(ctx, query, pq.Array(tags))
+	return err
+}
+
+// GetTrending returns the most used tags in the trailing window
+func (s *TagStore) GetTrending(ctx context.Context, window time.Duration, limit int) ([]TagCount, error) {
+	query := `
+		SELECT tag, SUM(count) AS total
+		FROM tag_usages
+		WHERE bucket >= date_trunc('hour', NOW() - $1 * interval '1 second')
+		GROUP BY tag
+		ORDER BY total DESC, tag
+		LIMIT $2
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, window.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var t TagCount
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+// Prune drops the buckets that fell out of every window
+func (s *TagStore) Prune(ctx context.Context, olderThan time.Duration) error {
+	query := `
+		DELETE FROM tag_usages WHERE bucket < NOW() - $1 * interval '1 second'
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	_, err := s.db.ExecContext(ctx, query, olderThan.Seconds())
+	return err
+}
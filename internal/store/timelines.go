@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// FeedEntry is the position of a post in a home timeline, at the time it was
+// posted or, for a repost, reposted
+type FeedEntry struct {
+	PostID int64
+	SortAt time.Time
+}
+
+// FeedEntryQuery selects the entries of a home timeline. Users with at least
+// CelebrityFollowers followers are not fanned out on write, Celebrities
+// selects the entries they posted or reposted, otherwise the ones of
+// everyone else. A CelebrityFollowers of 0 makes nobody a celebrity.
+type FeedEntryQuery struct {
+	Cursor             *FeedCursor
+	Limit              int
+	CelebrityFollowers int
+	Celebrities        bool
+}
+
+// GetFeedEntries lists the entries of the home timeline of userID, newest
+// first, as GetUserFeed orders them. The viewer filters are left to
+// GetByFeedEntries.
+func (s *PostStore) GetFeedEntries(ctx context.Context, userID int64, q FeedEntryQuery) ([]FeedEntry, error) {
+	threshold := q.CelebrityFollowers
+	if threshold <= 0 {
+		if q.Celebrities {
+			return []FeedEntry{}, nil
+		}
+		threshold = math.MaxInt32
+	}
+
+	var (
+		cursorAt *time.Time
+		cursorID int64
+	)
+	if q.Cursor != nil {
+		cursorAt = &q.Cursor.SortAt
+		cursorID = q.Cursor.PostID
+	}
+
+	// a user is a celebrity when their followers reach the threshold, the
+	// offset stops counting there
+	query := `
+		WITH authors AS (
+			SELECT a.id
+			FROM (
+				SELECT $1::bigint AS id
+				UNION
+				SELECT user_id FROM followers WHERE follower_id = $1 AND NOT ` + mutedBy("$1", "user_id") + `
+			) AS a
+			WHERE EXISTS (SELECT 1 FROM followers AS f WHERE f.user_id = a.id OFFSET $5 - 1) = $6
+		), entries AS (
+			SELECT DISTINCT ON (post_id) post_id, sort_at
+			FROM (
+				SELECT p.id AS post_id, p.created_at AS sort_at
+				FROM posts AS p
+				WHERE p.user_id IN (SELECT id FROM authors) AND p.thread_root_id IS NULL
+				UNION ALL
+				SELECT r.post_id, r.created_at
+				FROM reposts AS r
+				WHERE r.user_id IN (SELECT id FROM authors)
+			) AS e
+			ORDER BY post_id, sort_at DESC
+		)
+		SELECT post_id, sort_at
+		FROM entries AS e
+		WHERE $2::timestamptz IS NULL OR (e.sort_at, e.post_id) < ($2::timestamptz, $3::bigint)
+		ORDER BY sort_at DESC, post_id DESC
+		LIMIT $4
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, cursorAt, cursorID, q.Limit, threshold, q.Celebrities)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []FeedEntry{}
+	for rows.Next() {
+		var e FeedEntry
+		if err := rows.Scan(&e.PostID, &e.SortAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetByFeedEntries loads the posts of entries of the home timeline of
+// viewerID in their order, with the filters of GetUserFeed. An entry later
+// than its post is a repost, by the user the viewer follows who reposted it
+// at that time. Reposts by users the viewer muted or is blocked with are left
+// out, as are entries left with neither a followed author nor a reposter.
+func (s *PostStore) GetByFeedEntries(ctx context.Context, viewerID int64, entries []FeedEntry) ([]PostWithMetadata, error) {
+	if len(entries) == 0 {
+		return []PostWithMetadata{}, nil
+	}
+
+	// times are passed as unix seconds, the precision of posts and reposts
+	ids := make([]int64, len(entries))
+	times := make([]int64, len(entries))
+	for i, e := range entries {
+		ids[i] = e.PostID
+		times[i] = e.SortAt.Unix()
+	}
+
+	query := `
+		WITH entries AS (
+			SELECT e.post_id, to_timestamp(e.sort_at) AS sort_at, e.ord
+			FROM unnest($2::bigint[], $3::bigint[]) WITH ORDINALITY AS e (post_id, sort_at, ord)
+		)
+		SELECT ` + postWithMetadataColumns + `,
+				ru.id,
+				ru.username
+		FROM entries AS e
+		INNER JOIN posts AS p ON p.id = e.post_id
+		INNER JOIN users AS u ON p.user_id = u.id
+		CROSS JOIN LATERAL (
+			SELECT p.created_at = e.sort_at AND (p.user_id = $1 OR EXISTS (
+				SELECT 1 FROM followers AS f WHERE f.follower_id = $1 AND f.user_id = p.user_id
+			)) AS own
+		) AS a
+		LEFT JOIN LATERAL (
+			SELECT r.user_id
+			FROM reposts AS r
+			WHERE r.post_id = p.id AND r.created_at = e.sort_at
+				AND (r.user_id = $1 OR EXISTS (
+					SELECT 1 FROM followers AS f WHERE f.follower_id = $1 AND f.user_id = r.user_id
+				))
+				AND NOT ` + mutedBy("$1", "r.user_id") + `
+				AND NOT ` + blockedBetween("$1", "r.user_id") + `
+			ORDER BY r.user_id
+			LIMIT 1
+		) AS rp ON NOT a.own
+		LEFT JOIN users AS ru ON ru.id = rp.user_id
+		WHERE (a.own OR rp.user_id IS NOT NULL)
+			AND NOT ` + blockedBetween("$1", "p.user_id") + `
+			AND NOT ` + mutedBy("$1", "p.user_id") + `
+			AND ` + visibleTo("u", "$1") + `
+		ORDER BY e.ord
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, pq.Array(ids), pq.Array(times))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feed := []PostWithMetadata{}
+	for rows.Next() {
+		var (
+			reposterID   sql.NullInt64
+			reposterName sql.NullString
+		)
+
+		p, err := scanPostWithMetadata(rows, &reposterID, &reposterName)
+		if err != nil {
+			return nil, err
+		}
+
+		if reposterID.Valid {
+			p.RepostedBy = &User{ID: reposterID.Int64, Username: reposterName.String}
+		}
+
+		feed = append(feed, *p)
+	}
+
+	return feed, rows.Err()
+}
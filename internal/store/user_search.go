@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// UserSearchQuery finds people by username or display name. Autocomplete
+// only matches username prefixes, it is meant to be run on every key stroke
+// after an @.
+type UserSearchQuery struct {
+	Query        string `json:"q" validate:"required,max=100"`
+	Autocomplete bool   `json:"autocomplete"`
+	Limit        int    `json:"limit" validate:"gte=1,lte=50"`
+	Offset       int    `json:"offset" validate:"gte=0"`
+	ViewerID     int64  `json:"-"`
+}
+
+func (q UserSearchQuery) Parse(r *http.Request) (UserSearchQuery, error) {
+	qs := r.URL.Query()
+
+	q.Query = strings.TrimPrefix(strings.TrimSpace(qs.Get("q")), "@")
+	q.Autocomplete = qs.Get("mode") == "autocomplete"
+
+	pq, err := PaginatedQuery{Limit: q.Limit, Offset: q.Offset}.Parse(r)
+	if err != nil {
+		return q, err
+	}
+	q.Limit = pq.Limit
+	q.Offset = pq.Offset
+
+	return q, nil
+}
+
+// UserMatch is a user found by a UserSearchQuery
+type UserMatch struct {
+	User
+	FollowsYou bool `json:"follows_you"`
+	YouFollow  bool `json:"you_follow"`
+}
+
+// Search ranks users by how well their username or display name matches,
+// exact and prefix matches first then similar ones, boosted for the users
+// the viewer follows and more so for mutuals. Users the viewer is blocked
+// with are left out.
+func (s *UserStore) Search(ctx context.Context, q UserSearchQuery) ([]UserMatch, error) {
+	if q.Autocomplete {
+		return s.autocomplete(ctx, q)
+	}
+
+	query := `
+		WITH matches AS (
+			SELECT u.id, u.username, u.display_name, u.bio, u.avatar_key,
+				lower(u.username) AS username_lower, lower(u.display_name) AS display_name_lower,
+				EXISTS (SELECT 1 FROM followers AS f WHERE f.user_id = $3 AND f.follower_id = u.id) AS follows_you,
+				EXISTS (SELECT 1 FROM followers AS f WHERE f.user_id = u.id AND f.follower_id = $3) AS you_follow
+			FROM users AS u
+			WHERE u.is_active
+				AND (
+					lower(u.username) LIKE $2::text || '%'
+					OR lower(u.display_name) LIKE $2::text || '%'
+					OR lower(u.display_name) LIKE '% ' || $2::text || '%'
+					OR lower(u.username) % $1::text
+					OR lower(u.display_name) % $1
+				)
+				AND NOT ` + blockedBetween("$3", "u.id") + `
+		)
+		SELECT id, username, display_name, bio, avatar_key, follows_you, you_follow
+		FROM matches
+		ORDER BY
+			CASE
+				WHEN username_lower = $1 THEN 3
+				WHEN username_lower LIKE $2::text || '%' THEN 2
+				WHEN display_name_lower LIKE $2::text || '%' OR display_name_lower LIKE '% ' || $2::text || '%' THEN 1.5
+				ELSE 0
+			END
+			+ greatest(similarity(username_lower, $1), similarity(display_name_lower, $1))
+			+ CASE
+				WHEN you_follow AND follows_you THEN 2
+				WHEN you_follow THEN 1.5
+				WHEN follows_you THEN 0.5
+				ELSE 0
+			END DESC,
+			username
+		LIMIT $4 OFFSET $5
+	`
+
+	term := strings.ToLower(q.Query)
+
+	return s.searchUsers(ctx, query, term, escapeLike(term), q.ViewerID, q.Limit, q.Offset)
+}
+
+// autocomplete matches username prefixes only, which the prefix index
+// serves, people the viewer follows come first then shorter usernames
+func (s *UserStore) autocomplete(ctx context.Context, q UserSearchQuery) ([]UserMatch, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.bio, u.avatar_key,
+			EXISTS (SELECT 1 FROM followers AS f WHERE f.user_id = $2 AND f.follower_id = u.id) AS follows_you,
+			EXISTS (SELECT 1 FROM followers AS f WHERE f.user_id = u.id AND f.follower_id = $2) AS you_follow
+		FROM users AS u
+		WHERE lower(u.username) LIKE $1::text || '%' AND u.is_active
+			AND NOT ` + blockedBetween("$2", "u.id") + `
+		ORDER BY you_follow DESC, follows_you DESC, length(u.username), u.username
+		LIMIT $3 OFFSET $4
+	`
+
+	return s.searchUsers(ctx, query, escapeLike(strings.ToLower(q.Query)), q.ViewerID, q.Limit, q.Offset)
+}
+
+func (s *UserStore) searchUsers(ctx context.Context, query string, args ...any) ([]UserMatch, error) {
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserMatch{}
+	for rows.Next() {
+		var u UserMatch
+
+		err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.Bio, &u.AvatarKey, &u.FollowsYou, &u.YouFollow)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// escapeLike escapes the wildcards of LIKE, usernames often hold an _
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrUsernameCooldown = errors.New("the username was changed too recently")
+
+// UsernamePolicy rules username changes. A released username keeps
+// pointing to its previous owner for Redirect and nobody else can register
+// it for Reservation, so it cannot be used to impersonate them.
+type UsernamePolicy struct {
+	Cooldown    time.Duration
+	Redirect    time.Duration
+	Reservation time.Duration
+}
+
+// ChangeUsername renames a user and records the username they leave behind.
+// A user can take back their own previous usernames.
+func (s *UserStore) ChangeUsername(ctx context.Context, userID int64, username string, policy UsernamePolicy) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancle()
+
+		var previous string
+		err := tx.QueryRowContext(ctx, `SELECT username FROM users WHERE id = $1 AND is_active FOR UPDATE`, userID).Scan(&previous)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		if previous == username {
+			return nil
+		}
+
+		query := `
+			SELECT EXISTS (
+				SELECT 1 FROM username_history
+				WHERE user_id = $1 AND changed_at > NOW() - $2 * interval '1 second'
+			)
+		`
+
+		var cooling bool
+		if err := tx.QueryRowContext(ctx, query, userID, policy.Cooldown.Seconds()).Scan(&cooling); err != nil {
+			return err
+		}
+
+		if cooling {
+			return ErrUsernameCooldown
+		}
+
+		// usernames are unique regardless of case, only the owner can change
+		// the case of theirs
+		query = `SELECT EXISTS (SELECT 1 FROM users WHERE lower(username) = lower($1) AND id <> $2)`
+
+		var taken bool
+		if err := tx.QueryRowContext(ctx, query, username, userID).Scan(&taken); err != nil {
+			return err
+		}
+
+		reserved, err := usernameReserved(ctx, tx, username, userID)
+		if err != nil {
+			return err
+		}
+
+		if taken || reserved {
+			return ErrDuplicateUsername
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE users SET username = $1 WHERE id = $2`, username, userID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrDuplicateUsername
+			}
+			return err
+		}
+
+		query = `
+			INSERT INTO username_history (user_id, username, redirect_until, reserved_until)
+			VALUES ($1, $2, NOW() + $3 * interval '1 second', NOW() + $4 * interval '1 second')
+		`
+
+		_, err = tx.ExecContext(ctx, query, userID, previous, policy.Redirect.Seconds(), policy.Reservation.Seconds())
+		return err
+	})
+}
+
+// usernameReserved reports whether username was recently released by a
+// user other than userID
+func usernameReserved(ctx context.Context, tx *sql.Tx, username string, userID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM username_history
+			WHERE lower(username) = lower($1) AND user_id <> $2 AND reserved_until > NOW()
+		)
+	`
+
+	var reserved bool
+	err := tx.QueryRowContext(ctx, query, username, userID).Scan(&reserved)
+	return reserved, err
+}
+
+// previousUsernames selects the id and current username of the users who
+// left one of the lowercase usernames in $1 recently and whose old name was
+// not taken since, columns are the ones of a users row
+const previousUsernames = `
+	SELECT DISTINCT ON (lower(h.username)) u.id, u.username
+	FROM username_history AS h
+	INNER JOIN users AS u ON u.id = h.user_id
+	WHERE lower(h.username) = ANY($1) AND h.redirect_until > NOW() AND u.is_active
+		AND NOT EXISTS (SELECT 1 FROM users AS x WHERE lower(x.username) = lower(h.username))
+	ORDER BY lower(h.username), h.changed_at DESC
+`
+
+// LookupUsername finds the user going by username regardless of case and
+// returns their current username, which differs from username when it was
+// left recently and still redirects
+func (s *UserStore) LookupUsername(ctx context.Context, username string) (int64, string, error) {
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	var (
+		id      int64
+		current string
+	)
+
+	query := `SELECT id, username FROM users WHERE lower(username) = lower($1) AND is_active`
+
+	err := s.db.QueryRowContext(ctx, query, username).Scan(&id, &current)
+	if err == nil {
+		return id, current, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, "", err
+	}
+
+	err = s.db.QueryRowContext(ctx, previousUsernames, pq.Array([]string{strings.ToLower(username)})).Scan(&id, &current)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "", ErrNotFound
+		}
+		return 0, "", err
+	}
+
+	return id, current, nil
+}
@@ -25,6 +25,15 @@ type User struct {
 	IsActivate bool     `json:"is_active"`
 	RoleID     int64    `json:"role_id"`
 	Role       Role     `json:"role"`
+
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio,omitempty"`
+	Website     string `json:"website,omitempty"`
+	Location    string `json:"location,omitempty"`
+	AvatarKey   string `json:"-"`
+	AvatarURL   string `json:"avatar_url"`
+	// the posts of a private account are only seen by approved followers
+	IsPrivate bool `json:"is_private"`
 }
 
 type password struct {
@@ -55,7 +64,8 @@ type UserStore struct {
 
 func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 	query := `
-		SELECT users.id, username, email, password, created_at, roles.* 
+		SELECT users.id, username, email, password, created_at,
+			display_name, bio, website, location, avatar_key, is_private, roles.*
 		FROM users	
     JOIN roles ON (users.role_id = roles.id) 
 		WHERE users.id = $1 AND is_active = true
@@ -76,6 +86,12 @@ func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 		&user.Email,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.DisplayName,
+		&user.Bio,
+		&user.Website,
+		&user.Location,
+		&user.AvatarKey,
+		&user.IsPrivate,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Level,
@@ -92,6 +108,78 @@ func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 	return user, nil
 }
 
+// UpdateProfile saves the profile fields of user, a private account made
+// public approves the requests still pending
+func (s *UserStore) UpdateProfile(ctx context.Context, user *User) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE users
+			SET display_name = $1, bio = $2, website = $3, location = $4, is_private = $5
+			WHERE id = $6 AND is_active = true
+		`
+
+		ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancle()
+
+		res, err := tx.ExecContext(ctx, query, user.DisplayName, user.Bio, user.Website, user.Location, user.IsPrivate, user.ID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if user.IsPrivate {
+			return nil
+		}
+
+		approve := `
+			WITH approved AS (
+				DELETE FROM follow_requests AS fr
+				WHERE fr.user_id = $1 AND NOT ` + blockedBetween("fr.user_id", "fr.requester_id") + `
+				RETURNING fr.user_id, fr.requester_id
+			)
+			INSERT INTO followers (user_id, follower_id)
+			SELECT user_id, requester_id FROM approved
+			ON CONFLICT DO NOTHING
+		`
+
+		_, err = tx.ExecContext(ctx, approve, user.ID)
+		return err
+	})
+}
+
+// SetAvatar replaces the avatar of a user and returns the key of the one it
+// replaced, empty when there was none
+func (s *UserStore) SetAvatar(ctx context.Context, userID int64, key string) (string, error) {
+	query := `
+		UPDATE users AS u SET avatar_key = $1
+		FROM (SELECT id, avatar_key FROM users WHERE id = $2 FOR UPDATE) AS old
+		WHERE u.id = old.id AND u.is_active = true
+		RETURNING old.avatar_key
+	`
+
+	ctx, cancle := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancle()
+
+	var previous string
+	err := s.db.QueryRowContext(ctx, query, key, userID).Scan(&previous)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+
+	return previous, nil
+}
+
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
 		SELECT id, username, email, password, created_at 
@@ -138,7 +226,17 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 	if role == "" {
 		role = "user"
 	}
-	err := tx.QueryRowContext(
+
+	reserved, err := usernameReserved(ctx, tx, user.Username, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if reserved {
+		return ErrDuplicateUsername
+	}
+
+	err = tx.QueryRowContext(
 		ctx, query,
 		user.Username,
 		user.Password.hash,
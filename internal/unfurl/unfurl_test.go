@@ -0,0 +1,135 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+const page = `<!doctype html>
+<html>
+<head>
+	<title>Plain title</title>
+	<meta name="description" content="Plain description">
+	<meta property="og:title" content="The Go Gopher">
+	<meta property="og:site_name" content="Gophers">
+	<meta name="twitter:description" content="From the twitter card">
+	<meta property="og:image" content="/img/gopher.png">
+</head>
+<body><meta property="og:title" content="ignored"></body>
+</html>`
+
+func allowAll(netip.Addr) bool { return true }
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, page)
+		case "/moved":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{}`)
+		case "/large":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html><head><title>Large</title>"+strings.Repeat("<!-- padding -->", 1000))
+			fmt.Fprint(w, `<meta property="og:title" content="past the limit"></head></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	f := newFetcher(time.Second, 1<<10, allowAll)
+	ctx := context.Background()
+
+	p, err := f.Fetch(ctx, srv.URL+"/moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Preview{
+		URL:         srv.URL + "/moved",
+		Title:       "The Go Gopher",
+		Description: "From the twitter card",
+		ImageURL:    srv.URL + "/img/gopher.png",
+		SiteName:    "Gophers",
+	}
+	if *p != want {
+		t.Errorf("got %+v, want %+v", *p, want)
+	}
+
+	p, err = f.Fetch(ctx, srv.URL+"/large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Large" {
+		t.Errorf("expected the title read before the size limit, got %q", p.Title)
+	}
+
+	if _, err := f.Fetch(ctx, srv.URL+"/json"); !errors.Is(err, ErrNotHTML) {
+		t.Errorf("expected ErrNotHTML, got %v", err)
+	}
+
+	if _, err := f.Fetch(ctx, srv.URL+"/missing"); err == nil {
+		t.Error("expected an error for a 404")
+	}
+
+	if _, err := f.Fetch(ctx, "file:///etc/passwd"); err == nil {
+		t.Error("expected an error for a file URL")
+	}
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, page)
+	}))
+	defer srv.Close()
+
+	f := NewFetcher(time.Second, 1<<10)
+
+	if _, err := f.Fetch(context.Background(), srv.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("expected ErrBlockedAddress, got %v", err)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":          true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"0.1.2.3":          false,
+		"192.0.0.170":      false,
+		"198.18.0.1":       false,
+		"198.19.255.254":   false,
+		"240.0.0.1":        false,
+		"255.255.255.255":  false,
+		"64:ff9b::7f00:1":  false,
+		"64:ff9b::808:808": false,
+		"::1":              false,
+		"fd00::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+	}
+
+	for addr, want := range tests {
+		if got := isPublic(netip.MustParseAddr(addr).Unmap()); got != want {
+			t.Errorf("isPublic(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
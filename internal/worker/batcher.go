@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Batcher collects items in memory and hands them to flush in batches, every
+// interval or as soon as size distinct items are pending. Duplicates added
+// between two flushes are written once. Like Queue, Add never blocks the
+// caller, items are dropped while a full buffer waits to be flushed.
+type Batcher[T comparable] struct {
+	name     string
+	size     int
+	interval time.Duration
+	flush    func(context.Context, []T) error
+	logger   *zap.SugaredLogger
+
+	mu      sync.Mutex
+	pending map[T]struct{}
+	full    chan struct{}
+	done    chan struct{}
+}
+
+func NewBatcher[T comparable](name string, size int, interval time.Duration, logger *zap.SugaredLogger, flush func(context.Context, []T) error) *Batcher[T] {
+	return &Batcher[T]{
+		name:     name,
+		size:     size,
+		interval: interval,
+		flush:    flush,
+		logger:   logger,
+		pending:  make(map[T]struct{}, size),
+		full:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+func (b *Batcher[T]) Add(items ...T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, item := range items {
+		if len(b.pending) >= b.size {
+			b.logger.Warnw("batch is full, dropping items", "batcher", b.name)
+			break
+		}
+		b.pending[item] = struct{}{}
+	}
+
+	if len(b.pending) >= b.size {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Start flushes in the background until ctx is cancelled, what is pending
+// then is flushed one last time
+func (b *Batcher[T]) Start(ctx context.Context) {
+	go func() {
+		defer close(b.done)
+
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				// ctx is done, give the last flush its own deadline
+				final, cancel := context.WithTimeout(context.Background(), b.interval)
+				b.run(final)
+				cancel()
+				return
+			case <-ticker.C:
+				b.run(ctx)
+			case <-b.full:
+				b.run(ctx)
+			}
+		}
+	}()
+}
+
+// Wait blocks until the final flush is done after ctx was cancelled
+func (b *Batcher[T]) Wait() {
+	<-b.done
+}
+
+func (b *Batcher[T]) run(ctx context.Context) {
+	b.mu.Lock()
+	if len(b.pending) == 0 {
+		b.mu.Unlock()
+		return
+	}
+
+	batch := make([]T, 0, len(b.pending))
+	for item := range b.pending {
+		batch = append(batch, item)
+	}
+	b.pending = make(map[T]struct{}, b.size)
+	b.mu.Unlock()
+
+	if err := b.flush(ctx, batch); err != nil {
+		b.logger.Errorw("batch flush failed", "batcher", b.name, "items", len(batch), "error", err.Error())
+	}
+}
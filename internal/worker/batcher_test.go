@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestBatcher(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]int
+	)
+
+	flush := func(_ context.Context, batch []int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		slices.Sort(batch)
+		batches = append(batches, batch)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := NewBatcher("test", 3, time.Hour, zap.NewNop().Sugar(), flush)
+	b.Start(ctx)
+
+	// duplicates are written once and a full batch is flushed right away
+	b.Add(1, 1, 2, 3)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(batches)
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the full batch to be flushed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// what is pending is flushed on shutdown
+	b.Add(4)
+	cancel()
+	b.Wait()
+
+	want := [][]int{{1, 2, 3}, {4}}
+	if !slices.EqualFunc(batches, want, slices.Equal) {
+		t.Errorf("got batches %v, want %v", batches, want)
+	}
+}
@@ -0,0 +1,27 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Every runs fn in the background each interval until ctx is cancelled
+func Every(ctx context.Context, name string, interval time.Duration, logger *zap.SugaredLogger, fn func(context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := fn(ctx); err != nil {
+					logger.Errorw("periodic job failed", "job", name, "error", err.Error())
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Queue runs jobs in the background on a fixed number of goroutines.
+// Enqueue never blocks the caller, jobs are dropped when the buffer is full.
+type Queue[T any] struct {
+	name   string
+	jobs   chan T
+	handle func(context.Context, T) error
+	logger *zap.SugaredLogger
+	wg     sync.WaitGroup
+}
+
+func NewQueue[T any](name string, size int, logger *zap.SugaredLogger, handle func(context.Context, T) error) *Queue[T] {
+	return &Queue[T]{
+		name:   name,
+		jobs:   make(chan T, size),
+		handle: handle,
+		logger: logger,
+	}
+}
+
+func (q *Queue[T]) Start(ctx context.Context, workers int) {
+	for range workers {
+		q.wg.Add(1)
+		go q.run(ctx)
+	}
+}
+
+// Wait blocks until every worker has returned after ctx was cancelled
+func (q *Queue[T]) Wait() {
+	q.wg.Wait()
+}
+
+func (q *Queue[T]) Enqueue(job T) bool {
+	select {
+	case q.jobs <- job:
+		return true
+	default:
+		q.logger.Warnw("queue is full, dropping job", "queue", q.name)
+		return false
+	}
+}
+
+func (q *Queue[T]) run(ctx context.Context) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-q.jobs:
+			if err := q.handle(ctx, job); err != nil {
+				q.logger.Errorw("job failed", "queue", q.name, "error", err.Error())
+			}
+		}
+	}
+}